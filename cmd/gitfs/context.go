@@ -11,9 +11,10 @@ import (
 )
 
 var (
-	homeDir string
-	logsDir string
-	pidsDir string
+	homeDir  string
+	logsDir  string
+	pidsDir  string
+	cacheDir string
 )
 
 func init() {
@@ -25,11 +26,13 @@ func init() {
 	homeDir = userHomeDir + "/.gitfs"
 	logsDir = homeDir + "/logs"
 	pidsDir = homeDir + "/pids"
+	cacheDir = homeDir + "/cache"
 
 	for _, dir := range []string{
 		homeDir,
 		logsDir,
 		pidsDir,
+		cacheDir,
 	} {
 		err := os.MkdirAll(dir, 0755)
 		if err != nil {
@@ -66,3 +69,9 @@ func pidFileName(mountPoint string) string {
 func logFileName(mountPoint string) string {
 	return fmt.Sprintf("%s/%s-%s.log", logsDir, filepath.Base(mountPoint), mountPointHash(mountPoint))
 }
+
+// cacheDirByURL returns the directory keeping the clone of the remote repository.
+func cacheDirByURL(repositoryURL string) string {
+	hash := md5.Sum([]byte(repositoryURL))
+	return fmt.Sprintf("%s/%x", cacheDir, hash)
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/dsxack/gitfs/internal/alternates"
+	"github.com/dsxack/gitfs/internal/bundle"
+	"github.com/dsxack/gitfs/internal/gitdir"
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/dsxack/gitfs/internal/refwatch"
+	"github.com/dsxack/gitfs/internal/remote"
+	"github.com/dsxack/gitfs/internal/verify"
 	"github.com/dsxack/gitfs/nodes"
-	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing/cache"
+	gitstorage "github.com/go-git/go-git/v5/storage"
 	"github.com/go-git/go-git/v5/storage/filesystem"
-	"github.com/go-git/go-git/v5/storage/memory"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"github.com/spf13/cobra"
@@ -18,128 +27,387 @@ import (
 	"runtime"
 	"runtime/pprof"
 	"syscall"
+	"time"
 )
 
 var daemonModeFlag = false
 var verboseLevel int
+var reachableOnlyFlag = false
+var verifyKeyringFlag string
+var verifyAllowedSignersFlag string
+var fileHistoryModTimeFlag = false
+var fetchIntervalFlag time.Duration
+var cacheDirFlag string
+var inMemoryFlag = false
+var depthFlag int
+var singleBranchFlag = false
+var branchFlag string
+var tagsFlag = false
+var noTagsFlag = false
+var refSpecFlags []string
+var sshKeyFlag string
+var usernameFlag string
+var passwordFlag string
+var tokenFlag string
+var credentialHelperFlag = false
+var gitDirFlag string
+var reposDirFlag string
+var reposFileFlag string
 
 func init() {
 	mountCmd.Flags().CountVarP(&verboseLevel, "verbose", "v", "enable verbose output")
 	mountCmd.Flags().BoolVarP(&daemonModeFlag, "daemon", "d", false, "run in daemon mode")
+	mountCmd.Flags().BoolVar(
+		&reachableOnlyFlag, "reachable-only", false,
+		"show only commits reachable from references in commits directory",
+	)
+	mountCmd.Flags().StringVar(
+		&verifyKeyringFlag, "verify-keyring", "",
+		"armored PGP keyring to verify commit and tag signatures against",
+	)
+	mountCmd.Flags().StringVar(
+		&verifyAllowedSignersFlag, "verify-allowed-signers", "",
+		"SSH allowed signers file to verify commit and tag signatures against",
+	)
+	mountCmd.Flags().BoolVar(
+		&fileHistoryModTimeFlag, "file-history-mtime", false,
+		"report modification time of files as the time of the last commit that modified them",
+	)
+	mountCmd.Flags().DurationVar(
+		&fetchIntervalFlag, "fetch-interval", 0,
+		"fetch the remote repository mounted by URL every interval, SIGUSR1 fetches it immediately",
+	)
+	mountCmd.Flags().StringVar(
+		&cacheDirFlag, "cache-dir", "",
+		"directory keeping the clone of the remote repository mounted by URL (default ~/.gitfs/cache/<url hash>)",
+	)
+	mountCmd.Flags().BoolVar(
+		&inMemoryFlag, "in-memory", false,
+		"clone the remote repository mounted by URL into memory instead of the cache directory",
+	)
+	mountCmd.Flags().IntVar(
+		&depthFlag, "depth", 0,
+		"clone and fetch the remote repository mounted by URL with the history truncated to the number of commits",
+	)
+	mountCmd.Flags().BoolVar(
+		&singleBranchFlag, "single-branch", false,
+		"clone only the branch given by --branch or the remote HEAD of the repository mounted by URL",
+	)
+	mountCmd.Flags().StringVar(
+		&branchFlag, "branch", "",
+		"branch to clone of the remote repository mounted by URL instead of the remote HEAD",
+	)
+	mountCmd.Flags().BoolVar(
+		&tagsFlag, "tags", false,
+		"fetch all tags of the remote repository mounted by URL, even with --single-branch or --refspec",
+	)
+	mountCmd.Flags().BoolVar(
+		&noTagsFlag, "no-tags", false,
+		"fetch no tags of the remote repository mounted by URL",
+	)
+	mountCmd.Flags().StringArrayVar(
+		&refSpecFlags, "refspec", nil,
+		"refspec to fetch the remote repository mounted by URL with instead of all branches, can be repeated",
+	)
+	mountCmd.Flags().StringVar(
+		&sshKeyFlag, "ssh-key", "",
+		"private key file to authenticate to the remote repository mounted by SSH URL instead of the SSH agent "+
+			"(env GITFS_SSH_KEY, the passphrase is read from GITFS_SSH_KEY_PASSPHRASE)",
+	)
+	mountCmd.Flags().StringVar(
+		&usernameFlag, "username", "",
+		"username to authenticate to the remote repository mounted by HTTP URL (env GITFS_USERNAME)",
+	)
+	mountCmd.Flags().StringVar(
+		&passwordFlag, "password", "",
+		"password to authenticate to the remote repository mounted by HTTP URL, "+
+			"prefer GITFS_PASSWORD env, since arguments are visible to other users",
+	)
+	mountCmd.Flags().StringVar(
+		&tokenFlag, "token", "",
+		"access token to authenticate to the remote repository mounted by HTTP URL, "+
+			"prefer GITFS_TOKEN env, since arguments are visible to other users",
+	)
+	mountCmd.Flags().BoolVar(
+		&credentialHelperFlag, "credential-helper", false,
+		"ask the git credential helpers for the credentials of the remote repository mounted by HTTP URL up front, "+
+			"otherwise they are asked only after the remote rejects the request",
+	)
+	mountCmd.Flags().StringVar(
+		&gitDirFlag, "git-dir", "",
+		"git directory to mount as is instead of the repository argument, "+
+			"which is discovered by walking up the directories (env GIT_DIR)",
+	)
+	mountCmd.Flags().StringVar(
+		&reposDirFlag, "repos-dir", "",
+		"directory of repositories to mount each into the subdirectory named after it, "+
+			"the directory is read again on SIGHUP or gitfs reload",
+	)
+	mountCmd.Flags().StringVar(
+		&reposFileFlag, "repos-file", "",
+		"file listing repositories to mount each into the subdirectory, one \"[<name>] <repository>\" per line, "+
+			"the file is read again on SIGHUP or gitfs reload",
+	)
+	mountCmd.MarkFlagsMutuallyExclusive("tags", "no-tags")
 }
 
 var mountCmd = &cobra.Command{
-	Use:   "mount <repository> <mountpoint>",
+	Use:   "mount [<repository>...] <mountpoint>",
 	Short: "Mount git repository into directory",
-	Args:  cobra.ExactArgs(2),
+	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		var (
-			repositoryPath = args[0]
-			mountPoint     = args[1]
-		)
 		setupLogger()
 
+		if len(args) > 2 || reposDirFlag != "" || reposFileFlag != "" {
+			return mountRepositories(cmd, args[:len(args)-1], args[len(args)-1])
+		}
+
+		repositoryPath, mountPoint, discover, err := mountArgs(args)
+		if err != nil {
+			return err
+		}
+
+		remoteOptions, err := newRemoteOptions(repositoryPath)
+		if err != nil {
+			return err
+		}
+
 		if daemonModeFlag {
-			daemonContext, err := daemonContextByMountPoint(mountPoint)
-			if err != nil {
+			release, err := daemonize(cmd, mountPoint)
+			if err != nil || release == nil {
 				return err
 			}
+			defer release()
+		}
 
-			daemonProcess, err := daemonContext.Reborn()
-			if err != nil {
-				return fmt.Errorf("unable to run daemon process: %w", err)
-			}
-			if daemonProcess != nil {
-				cmd.Printf("Running in daemon mode, logs could be discovered in %s\n", daemonContext.LogFileName)
-				return nil
-			}
-			defer func() {
-				err := daemonContext.Release()
-				if err != nil {
-					cmd.Printf("unable to release daemon process context: %v", err)
-				}
-			}()
+		mounted, err := openMountedRepository(cmd, repositoryPath, discover, remoteOptions, cache.NewObjectLRUDefault())
+		if err != nil {
+			return err
 		}
+		defer mounted.close()
 
-		repository, err, cleanup := newRepository(cmd, repositoryPath)
+		nodeOptions, err := newNodeOptions()
 		if err != nil {
 			return err
 		}
-		defer cleanup()
+		nodeOptions.ReferencesWatched = mounted.referencesWatched()
 
 		cmd.Println("Mounting filesystem...")
-		server, err := fs.Mount(mountPoint, nodes.NewRootNode(repository), &fs.Options{
-			MountOptions: fuse.MountOptions{
-				Options: mountOptions(repositoryPath, mountPoint),
-				FsName:  fmt.Sprintf("gitfs: %s", filepath.Join(repositoryPath, git.GitDirName)),
-				Name:    "gitfs",
-				Debug:   verboseLevel > 2,
-			},
-		})
+		rootNode := nodes.NewRootNodeWithOptions(mounted.repository, nodeOptions)
+		server, err := mountFilesystem(
+			rootNode, mountPoint,
+			fmt.Sprintf("gitfs: %s", filepath.Join(repositoryPath, git.GitDirName)),
+			mountOptions(repositoryPath, mountPoint),
+		)
 		if err != nil {
-			return fmt.Errorf("failed to mount filesystem: %w", err)
+			return err
 		}
 		cmd.Printf("Filesystem successfully mounted into directory: %s\n", mountPoint)
 
-		go server.Wait()
+		mounted.serve(cmd, rootNode)
 
-		sigC := make(chan os.Signal, 1)
-		signal.Notify(
-			sigC,
-			syscall.SIGTERM,
-			syscall.SIGINT,
-			syscall.SIGQUIT,
-		)
+		fetch := func() {
+			if mounted.fetcher == nil {
+				cmd.Println("The repository is not mounted by URL, nothing to fetch")
+				return
+			}
+			cmd.Println("Fetching repository...")
+			mounted.fetcher.Trigger()
+		}
+		return handleSignals(cmd, server, fetch, nil)
+	},
+}
 
-		for {
-			sig := <-sigC
-
-			switch sig {
-			case syscall.SIGTERM, syscall.SIGINT:
-				cmd.Println("Received " + sig.String() + ", unmounting...")
-				err := server.Unmount()
-				if err != nil {
-					cmd.Printf("Failed to unmount filesystem: %s\n", err)
-					continue
-				}
-				return nil
-
-			case syscall.SIGQUIT:
-				cmd.Printf("Go version: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
-				_ = pprof.Lookup("goroutine").WriteTo(os.Stdout, 1)
+// daemonize runs the mount in the daemon process.
+// It returns nil release function in the parent process, which should exit then,
+// and the function releasing the daemon context in the daemon process.
+func daemonize(cmd *cobra.Command, mountPoint string) (func(), error) {
+	daemonContext, err := daemonContextByMountPoint(mountPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	daemonProcess, err := daemonContext.Reborn()
+	if err != nil {
+		return nil, fmt.Errorf("unable to run daemon process: %w", err)
+	}
+	if daemonProcess != nil {
+		cmd.Printf("Running in daemon mode, logs could be discovered in %s\n", daemonContext.LogFileName)
+		return nil, nil
+	}
+	return func() {
+		err := daemonContext.Release()
+		if err != nil {
+			cmd.Printf("unable to release daemon process context: %v", err)
+		}
+	}, nil
+}
+
+// newNodeOptions returns the options of the filesystem presentation of the repositories.
+func newNodeOptions() (nodes.Options, error) {
+	var verifier *verify.Verifier
+	if verifyKeyringFlag != "" || verifyAllowedSignersFlag != "" {
+		var err error
+		verifier, err = verify.NewVerifier(verifyKeyringFlag, verifyAllowedSignersFlag)
+		if err != nil {
+			return nodes.Options{}, fmt.Errorf("failed to create signature verifier: %w", err)
+		}
+	}
+	return nodes.Options{
+		ReachableCommitsOnly:   reachableOnlyFlag,
+		Verifier:               verifier,
+		FileModTimeFromHistory: fileHistoryModTimeFlag,
+	}, nil
+}
+
+// mountFilesystem mounts the root node into the mount point.
+func mountFilesystem(root fs.InodeEmbedder, mountPoint, fsName string, options []string) (*fuse.Server, error) {
+	cacheTimeout := nodes.MutableCacheTimeout
+	server, err := fs.Mount(mountPoint, root, &fs.Options{
+		// Immutable content sets longer timeouts itself.
+		EntryTimeout: &cacheTimeout,
+		AttrTimeout:  &cacheTimeout,
+		// Missing names, for example, parents of the commits at the shallow boundary,
+		// are looked up once per timeout.
+		NegativeTimeout: &cacheTimeout,
+		// Files and directories are owned by the user who mounted the filesystem.
+		UID: uint32(os.Getuid()),
+		GID: uint32(os.Getgid()),
+		MountOptions: fuse.MountOptions{
+			Options: options,
+			FsName:  fsName,
+			Name:    "gitfs",
+			Debug:   verboseLevel > 2,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to mount filesystem: %w", err)
+	}
+	go server.Wait()
+	return server, nil
+}
+
+// handleSignals serves the signals until the filesystem is unmounted by SIGTERM or SIGINT.
+// SIGUSR1 calls fetch, SIGHUP calls reload if it is given, SIGQUIT dumps the goroutines.
+func handleSignals(cmd *cobra.Command, server *fuse.Server, fetch func(), reload func()) error {
+	sigC := make(chan os.Signal, 1)
+	signals := []os.Signal{
+		syscall.SIGTERM,
+		syscall.SIGINT,
+		syscall.SIGQUIT,
+		syscall.SIGUSR1,
+	}
+	if reload != nil {
+		signals = append(signals, syscall.SIGHUP)
+	}
+	signal.Notify(sigC, signals...)
+
+	for {
+		sig := <-sigC
+
+		switch sig {
+		case syscall.SIGTERM, syscall.SIGINT:
+			cmd.Println("Received " + sig.String() + ", unmounting...")
+			err := server.Unmount()
+			if err != nil {
+				cmd.Printf("Failed to unmount filesystem: %s\n", err)
 				continue
 			}
+			return nil
+
+		case syscall.SIGUSR1:
+			cmd.Println("Received " + sig.String())
+			fetch()
+			continue
+
+		case syscall.SIGHUP:
+			cmd.Println("Received " + sig.String() + ", reloading repositories...")
+			reload()
+			continue
+
+		case syscall.SIGQUIT:
+			cmd.Printf("Go version: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+			_ = pprof.Lookup("goroutine").WriteTo(os.Stdout, 1)
+			continue
 		}
-	},
+	}
 }
 
-func newRepository(cmd *cobra.Command, repositoryURL string) (*git.Repository, error, func()) {
+// mountArgs returns the repository and the mount point of the arguments,
+// and whether the git directory of the repository is discovered.
+// If the repository is omitted, the git directory given by --git-dir or GIT_DIR is mounted as is.
+func mountArgs(args []string) (string, string, bool, error) {
+	if len(args) == 2 {
+		if gitDirFlag != "" {
+			return "", "", false, fmt.Errorf("--git-dir and repository must not be given together")
+		}
+		return args[0], args[1], true, nil
+	}
+	gitDir := flagOrEnv(gitDirFlag, "GIT_DIR")
+	if gitDir == "" {
+		return "", "", false, fmt.Errorf("repository is required unless --git-dir or GIT_DIR is given")
+	}
+	if _, err := os.Stat(gitDir); err != nil {
+		return "", "", false, fmt.Errorf("git directory: %w", err)
+	}
+	return gitDir, args[0], false, nil
+}
+
+func newRepository(
+	cmd *cobra.Command,
+	repositoryURL string,
+	discover bool,
+	options remote.Options,
+	objectCache cache.Object,
+) (*git.Repository, error, func()) {
 	dummyCleanup := func() {}
 
-	_, err := os.Stat(repositoryURL)
-	if os.IsNotExist(err) {
+	if isRemoteURL(repositoryURL) && !inMemoryFlag {
+		dir := cacheDirFlag
+		if dir == "" {
+			dir = cacheDirByURL(repositoryURL)
+		}
+		cmd.Printf("Opening repository %s cached in %s\n", repositoryURL, dir)
+		r, storage, err := remote.OpenCache(context.Background(), dir, repositoryURL, options, objectCache, cmd.OutOrStderr())
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cached repository: %w", err), dummyCleanup
+		}
+		cleanup := func() {
+			err := storage.Close()
+			if err != nil {
+				cmd.Println("failed to close storage:", err)
+			}
+		}
+		return r, nil, cleanup
+	}
+	if isRemoteURL(repositoryURL) {
 		cmd.Printf("Cloning repository %s into memory\n", repositoryURL)
-		storage := memory.NewStorage()
-		r, err := git.Clone(storage, nil, &git.CloneOptions{
-			NoCheckout: true,
-			URL:        repositoryURL,
-			Progress:   cmd.OutOrStderr(),
-		})
+		storage := remote.NewMemoryStorage()
+		r, err := remote.Clone(context.Background(), storage, repositoryURL, options, cmd.OutOrStderr())
 		if err != nil {
 			return nil, fmt.Errorf("failed to clone repository: %w", err), dummyCleanup
 		}
 		return r, nil, dummyCleanup
 	}
-	workDirFS := osfs.New(repositoryURL)
-	if _, err := workDirFS.Stat(git.GitDirName); err == nil {
-		workDirFS, err = workDirFS.Chroot(git.GitDirName)
+	if bundle.IsBundle(repositoryURL) {
+		cmd.Printf("Loading bundle %s into memory\n", repositoryURL)
+		r, err := bundle.Open(repositoryURL)
 		if err != nil {
-			return nil, fmt.Errorf("failed to chroot to git directory: %w", err), dummyCleanup
+			return nil, fmt.Errorf("failed to open bundle: %w", err), dummyCleanup
 		}
+		return r, nil, dummyCleanup
+	}
+	findGitDir := gitdir.Resolve
+	if discover {
+		findGitDir = gitdir.Find
+	}
+	gitDir, err := findGitDir(repositoryURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find git directory: %w", err), dummyCleanup
 	}
 	storage := filesystem.NewStorageWithOptions(
-		workDirFS,
-		cache.NewObjectLRUDefault(),
+		gitDir.Filesystem(),
+		objectCache,
 		filesystem.Options{KeepDescriptors: true},
 	)
 	cleanup := func() {
@@ -148,13 +416,110 @@ func newRepository(cmd *cobra.Command, repositoryURL string) (*git.Repository, e
 			cmd.Println("failed to close storage:", err)
 		}
 	}
-	repository, err := git.Open(storage, nil)
+	alternateDirs, err := alternates.Resolve(filepath.Join(gitDir.CommonPath, "objects"))
+	if err != nil {
+		cmd.Printf("Some objects are missing, since their alternate object directories are unreachable: %s\n", err)
+	}
+	if err := objectformat.Check(storage); err != nil {
+		return nil, fmt.Errorf("failed to open repository: %w", err), cleanup
+	}
+	var storer gitstorage.Storer = storage
+	if len(alternateDirs) > 0 {
+		storer = alternates.NewStorage(storage, alternateDirs, objectCache)
+	}
+	repository, err := git.Open(storer, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open repository: %w", err), cleanup
 	}
 	return repository, nil, cleanup
 }
 
+// newRemoteOptions returns the options to clone and fetch the repository mounted by URL with.
+// The credentials are resolved without prompting, so they are resolved the same way in daemon mode.
+func newRemoteOptions(repositoryURL string) (remote.Options, error) {
+	options, err := remoteOptionsFromFlags()
+	if err != nil || !isRemoteURL(repositoryURL) {
+		return options, err
+	}
+	credentials := remote.Credentials{
+		SSHKeyFile:       flagOrEnv(sshKeyFlag, "GITFS_SSH_KEY"),
+		SSHKeyPassphrase: os.Getenv("GITFS_SSH_KEY_PASSPHRASE"),
+		Username:         flagOrEnv(usernameFlag, "GITFS_USERNAME"),
+		Password:         flagOrEnv(passwordFlag, "GITFS_PASSWORD"),
+		Token:            flagOrEnv(tokenFlag, "GITFS_TOKEN"),
+		CredentialHelper: credentialHelperFlag,
+	}
+	auth, err := remote.NewAuth(context.Background(), repositoryURL, credentials)
+	if err != nil {
+		return options, fmt.Errorf("failed to authenticate to remote repository: %w", err)
+	}
+	options.Auth = auth
+	options.Reauth = remote.NewReauth(repositoryURL, credentials)
+	return options, nil
+}
+
+// remoteOptionsFromFlags returns the options to clone and fetch the repositories mounted by URL with,
+// except the credentials, which depend on the URL.
+func remoteOptionsFromFlags() (remote.Options, error) {
+	options := remote.Options{
+		Depth:        depthFlag,
+		Branch:       branchFlag,
+		SingleBranch: singleBranchFlag,
+	}
+	if depthFlag < 0 {
+		return options, fmt.Errorf("depth must not be negative: %d", depthFlag)
+	}
+	switch {
+	case tagsFlag:
+		options.Tags = git.AllTags
+	case noTagsFlag:
+		options.Tags = git.NoTags
+	}
+	for _, refSpec := range refSpecFlags {
+		refSpec := config.RefSpec(refSpec)
+		if err := refSpec.Validate(); err != nil {
+			return options, fmt.Errorf("invalid refspec %s: %w", refSpec, err)
+		}
+		options.RefSpecs = append(options.RefSpecs, refSpec)
+	}
+	return options, nil
+}
+
+// flagOrEnv returns the value of the flag, or of the environment variable if the flag is not set.
+func flagOrEnv(flag, env string) string {
+	if flag != "" {
+		return flag
+	}
+	return os.Getenv(env)
+}
+
+// newReferenceWatcher returns the watcher of the references of the repository on the filesystem,
+// or nil if they can't be watched, so the changes are seen after the cache of the references expires.
+func newReferenceWatcher(cmd *cobra.Command, repository *git.Repository) *refwatch.Watcher {
+	fsStorer, ok := repository.Storer.(interface{ Filesystem() billy.Filesystem })
+	if !ok {
+		return nil
+	}
+	// The references of a linked worktree are shared with the main worktree in the common directory.
+	gitDir, err := gitdir.Resolve(fsStorer.Filesystem().Root())
+	if err != nil {
+		cmd.Printf("Failed to watch references, changes will be seen after the cache expires: %s\n", err)
+		return nil
+	}
+	watcher, err := refwatch.New(gitDir.Path, gitDir.CommonPath)
+	if err != nil {
+		cmd.Printf("Failed to watch references, changes will be seen after the cache expires: %s\n", err)
+		return nil
+	}
+	return watcher
+}
+
+// isRemoteURL reports whether the repository is mounted by URL, so it is cloned instead of opened.
+func isRemoteURL(repositoryURL string) bool {
+	_, err := os.Stat(repositoryURL)
+	return os.IsNotExist(err)
+}
+
 func mountOptions(repositoryPath, mountPoint string) []string {
 	var options []string
 
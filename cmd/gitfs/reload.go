@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"syscall"
+)
+
+var reloadCmd = &cobra.Command{
+	Use:   "reload <mountPoint>",
+	Short: "Reload repositories of the mount from its repositories directory and file",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mountPoint, err := filepath.Abs(args[0])
+		if err != nil {
+			return fmt.Errorf("unable to get absolute path for '%s': %w", args[0], err)
+		}
+
+		daemonContext, err := daemonContextByMountPoint(mountPoint)
+		if err != nil {
+			return err
+		}
+
+		daemonProcess, err := daemonContext.Search()
+		if err != nil {
+			return fmt.Errorf("unable to reload '%s': maybe it is not mounted", mountPoint)
+		}
+
+		err = daemonProcess.Signal(syscall.SIGHUP)
+		if err != nil {
+			return fmt.Errorf("unable to signal daemon process: %w", err)
+		}
+
+		return nil
+	},
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/bundle"
+	"github.com/dsxack/gitfs/internal/refwatch"
+	"github.com/dsxack/gitfs/internal/remote"
+	"github.com/dsxack/gitfs/internal/repolist"
+	"github.com/dsxack/gitfs/nodes"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/spf13/cobra"
+	"slices"
+	"sync"
+)
+
+// mountedRepository is the mounted repository together with the watcher of its references
+// and the fetcher of the repository mounted by URL.
+type mountedRepository struct {
+	path       string
+	repository *git.Repository
+	options    remote.Options
+	watcher    *refwatch.Watcher
+	fetcher    *remote.Fetcher
+	// closers are called in the reverse order to stop serving the repository.
+	closers []func()
+	// cleanup closes the storage of the repository.
+	cleanup func()
+
+	storageMu     sync.Mutex
+	storageClosed bool
+}
+
+// openMountedRepository opens the repository to mount, see newRepository.
+func openMountedRepository(
+	cmd *cobra.Command,
+	repositoryPath string,
+	discover bool,
+	options remote.Options,
+	objectCache cache.Object,
+) (*mountedRepository, error) {
+	repository, err, cleanup := newRepository(cmd, repositoryPath, discover, options, objectCache)
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	mounted := &mountedRepository{
+		path:       repositoryPath,
+		repository: repository,
+		options:    options,
+		cleanup:    cleanup,
+	}
+	if !isRemoteURL(repositoryPath) && !bundle.IsBundle(repositoryPath) {
+		mounted.watcher = newReferenceWatcher(cmd, repository)
+	}
+	if mounted.watcher != nil {
+		mounted.closers = append(mounted.closers, func() {
+			if err := mounted.watcher.Close(); err != nil {
+				cmd.Printf("Failed to stop watching references: %s\n", err)
+			}
+		})
+	}
+	return mounted, nil
+}
+
+// referencesWatched reports whether every change of the references is noticed: they are watched,
+// fetched by the fetcher of the repository mounted by URL, or never change in a bundle.
+func (mounted *mountedRepository) referencesWatched() bool {
+	return mounted.watcher != nil || isRemoteURL(mounted.path) || bundle.IsBundle(mounted.path)
+}
+
+// serve starts watching the references of the repository, and fetching the repository mounted by URL,
+// the root node cache of the references is invalidated when they change.
+func (mounted *mountedRepository) serve(cmd *cobra.Command, rootNode *nodes.RootNode) {
+	if mounted.watcher != nil {
+		go func() {
+			for range mounted.watcher.Changes() {
+				rootNode.InvalidateReferences(context.Background())
+			}
+		}()
+	}
+	if !isRemoteURL(mounted.path) {
+		return
+	}
+	mounted.fetcher = remote.NewFetcher(
+		mounted.repository, git.DefaultRemoteName, mounted.options, fetchIntervalFlag,
+		func() {
+			rootNode.InvalidateReferences(context.Background())
+		},
+	)
+	fetchContext, stopFetch := context.WithCancel(context.Background())
+	mounted.closers = append(mounted.closers, stopFetch)
+	go mounted.fetcher.Run(fetchContext)
+}
+
+// close stops serving the repository and closes its storage.
+func (mounted *mountedRepository) close() {
+	mounted.stop()
+	mounted.closeStorage()
+}
+
+// stop stops watching the references and fetching the repository.
+func (mounted *mountedRepository) stop() {
+	for i := len(mounted.closers) - 1; i >= 0; i-- {
+		mounted.closers[i]()
+	}
+	mounted.closers = nil
+}
+
+// closeStorage closes the storage of the repository once, it is called by the filesystem
+// once the removed repository is forgotten, or after the filesystem is unmounted.
+func (mounted *mountedRepository) closeStorage() {
+	mounted.storageMu.Lock()
+	defer mounted.storageMu.Unlock()
+	if mounted.storageClosed {
+		return
+	}
+	mounted.storageClosed = true
+	mounted.cleanup()
+}
+
+// isStorageClosed reports whether the storage of the repository is closed.
+func (mounted *mountedRepository) isStorageClosed() bool {
+	mounted.storageMu.Lock()
+	defer mounted.storageMu.Unlock()
+	return mounted.storageClosed
+}
+
+// mountRepositories mounts the repositories given by the arguments, --repos-dir and --repos-file
+// into the subdirectories of the mount point named after them.
+// The directory and the file are read again on SIGHUP, mounting the repositories added to them
+// and unmounting the removed ones. The repositories share the object cache.
+func mountRepositories(cmd *cobra.Command, paths []string, mountPoint string) error {
+	if gitDirFlag != "" {
+		return fmt.Errorf("--git-dir must not be given together with several repositories")
+	}
+	if cacheDirFlag != "" {
+		return fmt.Errorf("--cache-dir must not be given together with several repositories")
+	}
+	if _, err := listRepositories(paths); err != nil {
+		return err
+	}
+	if _, err := remoteOptionsFromFlags(); err != nil {
+		return err
+	}
+
+	if daemonModeFlag {
+		release, err := daemonize(cmd, mountPoint)
+		if err != nil || release == nil {
+			return err
+		}
+		defer release()
+	}
+
+	nodeOptions, err := newNodeOptions()
+	if err != nil {
+		return err
+	}
+
+	cmd.Println("Mounting filesystem...")
+	set := &repositorySet{
+		root:         nodes.NewMultiRootNode(),
+		nodeOptions:  nodeOptions,
+		objectCache:  cache.NewObjectLRUDefault(),
+		repositories: make(map[string]*mountedRepository),
+	}
+	server, err := mountFilesystem(
+		set.root, mountPoint,
+		fmt.Sprintf("gitfs: %s", mountPoint),
+		mountOptions("repositories", mountPoint),
+	)
+	if err != nil {
+		return err
+	}
+	cmd.Printf("Filesystem successfully mounted into directory: %s\n", mountPoint)
+	defer set.close()
+
+	reload := func() {
+		repositories, err := listRepositories(paths)
+		if err != nil {
+			cmd.Printf("Failed to list repositories, keeping the mounted ones: %s\n", err)
+			return
+		}
+		set.sync(cmd, repositories)
+	}
+	reload()
+
+	return handleSignals(cmd, server, func() { set.fetch(cmd) }, reload)
+}
+
+// listRepositories returns the repositories given by the arguments, --repos-dir and --repos-file.
+func listRepositories(paths []string) ([]repolist.Repository, error) {
+	lists := [][]repolist.Repository{repolist.FromPaths(paths)}
+	if reposDirFlag != "" {
+		repositories, err := repolist.ReadDir(reposDirFlag)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, repositories)
+	}
+	if reposFileFlag != "" {
+		repositories, err := repolist.ReadFile(reposFileFlag)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, repositories)
+	}
+	return repolist.Merge(lists...)
+}
+
+// repositorySet is the set of repositories mounted into the subdirectories of the mount point.
+// It is used by the signal handling goroutine only.
+type repositorySet struct {
+	root         *nodes.MultiRootNode
+	nodeOptions  nodes.Options
+	objectCache  cache.Object
+	repositories map[string]*mountedRepository
+	// removed are the repositories unmounted from the subdirectories, their storages are closed
+	// once the kernel forgets their nodes, or after the filesystem is unmounted.
+	removed []*mountedRepository
+}
+
+// sync mounts the listed repositories which are not mounted yet, and unmounts the mounted ones
+// which are not listed anymore. The repository listed under the same name with another path is remounted.
+// The repositories failed to open are reported and skipped, so the others are mounted anyway.
+func (set *repositorySet) sync(cmd *cobra.Command, repositories []repolist.Repository) {
+	listed := make(map[string]string, len(repositories))
+	for _, repository := range repositories {
+		listed[repository.Name] = repository.Path
+	}
+	names := make([]string, 0, len(set.repositories))
+	for name := range set.repositories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if path, ok := listed[name]; !ok || path != set.repositories[name].path {
+			set.remove(cmd, name)
+		}
+	}
+	for _, repository := range repositories {
+		if _, ok := set.repositories[repository.Name]; ok {
+			continue
+		}
+		if err := set.add(cmd, repository); err != nil {
+			cmd.Printf("Failed to mount repository %s: %s\n", repository.Name, err)
+		}
+	}
+}
+
+func (set *repositorySet) add(cmd *cobra.Command, repository repolist.Repository) error {
+	options, err := newRemoteOptions(repository.Path)
+	if err != nil {
+		return err
+	}
+	mounted, err := openMountedRepository(cmd, repository.Path, true, options, set.objectCache)
+	if err != nil {
+		return err
+	}
+	nodeOptions := set.nodeOptions
+	nodeOptions.ReferencesWatched = mounted.referencesWatched()
+	rootNode, err := set.root.Add(context.Background(), repository.Name, mounted.repository, nodeOptions)
+	if err != nil {
+		mounted.close()
+		return err
+	}
+	mounted.serve(cmd, rootNode)
+	set.repositories[repository.Name] = mounted
+	cmd.Printf("Repository %s mounted into %s\n", repository.Path, repository.Name)
+	return nil
+}
+
+func (set *repositorySet) remove(cmd *cobra.Command, name string) {
+	mounted := set.repositories[name]
+	mounted.stop()
+	if err := set.root.Remove(context.Background(), name, mounted.closeStorage); err != nil {
+		cmd.Printf("Failed to unmount repository %s: %s\n", name, err)
+		mounted.closeStorage()
+	}
+	delete(set.repositories, name)
+	set.removed = append(slices.DeleteFunc(set.removed, (*mountedRepository).isStorageClosed), mounted)
+	cmd.Printf("Repository %s unmounted from %s\n", mounted.path, name)
+}
+
+// fetch triggers the fetch of the repositories mounted by URL.
+func (set *repositorySet) fetch(cmd *cobra.Command) {
+	fetched := false
+	for name, mounted := range set.repositories {
+		if mounted.fetcher == nil {
+			continue
+		}
+		cmd.Printf("Fetching repository %s...\n", name)
+		mounted.fetcher.Trigger()
+		fetched = true
+	}
+	if !fetched {
+		cmd.Println("No repositories are mounted by URL, nothing to fetch")
+	}
+}
+
+// close closes the repositories after the filesystem is unmounted.
+func (set *repositorySet) close() {
+	for _, mounted := range set.repositories {
+		mounted.close()
+	}
+	for _, mounted := range set.removed {
+		mounted.closeStorage()
+	}
+}
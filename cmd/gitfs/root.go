@@ -11,5 +11,6 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(mountCmd)
 	rootCmd.AddCommand(umountCmd)
+	rootCmd.AddCommand(reloadCmd)
 	rootCmd.AddCommand(versionCmd)
 }
@@ -0,0 +1,215 @@
+// Package alternates reads the objects of the alternate object directories shared between repositories.
+package alternates
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/mount"
+	"github.com/go-git/go-billy/v5/helper/polyfill"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxDepth is the depth of the alternates of alternates git follows.
+const maxDepth = 5
+
+// objectsDirName is the name of the object directory in the git directory.
+const objectsDirName = "objects"
+
+// alternatesFile is the file of the objects directory listing its alternate object directories.
+var alternatesFile = filepath.Join("info", "alternates")
+
+var _ storer.EncodedObjectStorer = (*Storage)(nil)
+
+// Resolve returns the alternate object directories of the objects directory listed in its
+// info/alternates file, followed by their own alternates, in the order git searches them.
+// Relative paths are relative to the objects directory listing them, the same as git resolves them.
+// The unreachable directories are skipped and reported in the returned error
+// together with the reachable ones, so the repository can still be used without them.
+func Resolve(objectsDir string) ([]string, error) {
+	resolver := &resolver{seen: map[string]bool{filepath.Clean(objectsDir): true}}
+	resolver.resolve(filepath.Clean(objectsDir), 0)
+	return resolver.dirs, errors.Join(resolver.errs...)
+}
+
+type resolver struct {
+	seen map[string]bool
+	dirs []string
+	errs []error
+}
+
+func (resolver *resolver) resolve(objectsDir string, depth int) {
+	content, err := os.ReadFile(filepath.Join(objectsDir, alternatesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		resolver.errs = append(resolver.errs, fmt.Errorf("read alternates of %s: %w", objectsDir, err))
+		return
+	}
+	if depth >= maxDepth {
+		resolver.errs = append(resolver.errs, fmt.Errorf("alternates of %s are nested too deep", objectsDir))
+		return
+	}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dir := line
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(objectsDir, dir)
+		}
+		dir = filepath.Clean(dir)
+		if resolver.seen[dir] {
+			continue
+		}
+		resolver.seen[dir] = true
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			resolver.errs = append(resolver.errs, fmt.Errorf(
+				"alternate object directory %s listed in %s is unreachable",
+				dir, filepath.Join(objectsDir, alternatesFile),
+			))
+			continue
+		}
+		resolver.dirs = append(resolver.dirs, dir)
+		resolver.resolve(dir, depth+1)
+	}
+}
+
+// Storage is the repository storage reading the objects missing from it
+// from the alternate object directories.
+type Storage struct {
+	*filesystem.Storage
+	alternates  []*filesystem.ObjectStorage
+	filesystems []billy.Filesystem
+}
+
+// NewStorage wraps the storage to read the objects missing from it from the alternate object directories.
+// The objects read from the alternates are cached in the object cache.
+func NewStorage(storage *filesystem.Storage, dirs []string, objectCache cache.Object) *Storage {
+	alternates := make([]*filesystem.ObjectStorage, 0, len(dirs))
+	filesystems := make([]billy.Filesystem, 0, len(dirs))
+	for _, dir := range dirs {
+		fs := objectsFilesystem(dir)
+		alternates = append(alternates, filesystem.NewObjectStorage(dotgit.New(fs), objectCache))
+		filesystems = append(filesystems, fs)
+	}
+	return &Storage{Storage: storage, alternates: alternates, filesystems: filesystems}
+}
+
+// AlternateFilesystems returns the filesystems of the alternate object directories,
+// each of them has the directory as its "objects" directory, the same as the git directory has.
+func (s *Storage) AlternateFilesystems() []billy.Filesystem {
+	return s.filesystems
+}
+
+// objectsFilesystem returns the git directory filesystem having the object directory as its "objects",
+// since the object storage reads the objects from there, and the alternate may be named otherwise.
+func objectsFilesystem(dir string) billy.Filesystem {
+	return polyfill.New(mount.New(memfs.New(), objectsDirName, osfs.New(dir)))
+}
+
+// EncodedObject returns the object from the storage, or from the first alternate having it.
+func (s *Storage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	obj, err := s.Storage.EncodedObject(t, h)
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return obj, err
+	}
+	for _, alternate := range s.alternates {
+		obj, err := alternate.EncodedObject(t, h)
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return obj, err
+		}
+	}
+	return nil, err
+}
+
+// HasEncodedObject returns nil if the storage or any alternate has the object.
+func (s *Storage) HasEncodedObject(h plumbing.Hash) error {
+	err := s.Storage.HasEncodedObject(h)
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return err
+	}
+	for _, alternate := range s.alternates {
+		if err := alternate.HasEncodedObject(h); !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return err
+		}
+	}
+	return err
+}
+
+// EncodedObjectSize returns the size of the object from the storage, or from the first alternate having it.
+func (s *Storage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	size, err := s.Storage.EncodedObjectSize(h)
+	if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		return size, err
+	}
+	for _, alternate := range s.alternates {
+		size, err := alternate.EncodedObjectSize(h)
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return size, err
+		}
+	}
+	return 0, err
+}
+
+// IterEncodedObjects iterates the objects of the storage and the alternates,
+// each object is returned once even if it is stored in several of them.
+func (s *Storage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	iters := make([]storer.EncodedObjectIter, 0, len(s.alternates)+1)
+	closeIters := func() {
+		for _, iter := range iters {
+			iter.Close()
+		}
+	}
+	iter, err := s.Storage.IterEncodedObjects(t)
+	if err != nil {
+		return nil, err
+	}
+	iters = append(iters, iter)
+	for _, alternate := range s.alternates {
+		iter, err := alternate.IterEncodedObjects(t)
+		if err != nil {
+			closeIters()
+			return nil, err
+		}
+		iters = append(iters, iter)
+	}
+	return &uniqueIter{EncodedObjectIter: storer.NewMultiEncodedObjectIter(iters), seen: make(map[plumbing.Hash]struct{})}, nil
+}
+
+// uniqueIter skips the objects already returned.
+type uniqueIter struct {
+	storer.EncodedObjectIter
+	seen map[plumbing.Hash]struct{}
+}
+
+func (iter *uniqueIter) Next() (plumbing.EncodedObject, error) {
+	for {
+		obj, err := iter.EncodedObjectIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := iter.seen[obj.Hash()]; ok {
+			continue
+		}
+		iter.seen[obj.Hash()] = struct{}{}
+		return obj, nil
+	}
+}
+
+func (iter *uniqueIter) ForEach(cb func(plumbing.EncodedObject) error) error {
+	return storer.ForEachIterator(iter, cb)
+}
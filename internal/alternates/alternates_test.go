@@ -0,0 +1,152 @@
+package alternates
+
+import (
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStorage(t *testing.T) {
+	root := t.TempDir()
+	// base <- shared <- clone: every repository keeps only its own commit,
+	// the clone reads the others through the chained alternates.
+	runGit(t, root, "init", "-q", "--object-format="+string(objectformat.Current()), "-b", "master", "base")
+	runGit(t, filepath.Join(root, "base"), "commit", "-q", "--allow-empty", "-m", "base")
+	runGit(t, root, "clone", "-q", "--shared", "base", "shared")
+	runGit(t, filepath.Join(root, "shared"), "commit", "-q", "--allow-empty", "-m", "shared")
+	runGit(t, root, "clone", "-q", "--shared", "shared", "clone")
+	runGit(t, filepath.Join(root, "clone"), "commit", "-q", "--allow-empty", "-m", "clone")
+	objectsDir := filepath.Join(root, "clone", ".git", "objects")
+	// The relative alternate is relative to the objects directory.
+	writeAlternates(t, objectsDir, "../../../shared/.git/objects")
+
+	dirs, err := Resolve(objectsDir)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(root, "shared", ".git", "objects"),
+		filepath.Join(root, "base", ".git", "objects"),
+	}, dirs)
+
+	storage := filesystem.NewStorage(osfs.New(filepath.Join(root, "clone", ".git")), cache.NewObjectLRUDefault())
+	repository, err := git.Open(NewStorage(storage, dirs, cache.NewObjectLRUDefault()), nil)
+	require.NoError(t, err)
+	head, err := repository.Head()
+	require.NoError(t, err)
+	var messages []string
+	commits, err := repository.Log(&git.LogOptions{From: head.Hash()})
+	require.NoError(t, err)
+	require.NoError(t, commits.ForEach(func(commit *object.Commit) error {
+		messages = append(messages, strings.TrimSpace(commit.Message))
+		return nil
+	}))
+	require.Equal(t, []string{"clone", "shared", "base"}, messages)
+
+	first, err := repository.CommitObject(plumbing.NewHash(revParse(t, filepath.Join(root, "base"), "HEAD")))
+	require.NoError(t, err)
+	require.NoError(t, repository.Storer.HasEncodedObject(first.Hash))
+	_, err = repository.Storer.EncodedObjectSize(first.Hash)
+	require.NoError(t, err)
+
+	// The empty tree is stored in every repository, but it is listed once.
+	var hashes []plumbing.Hash
+	objects, err := repository.Storer.IterEncodedObjects(plumbing.AnyObject)
+	require.NoError(t, err)
+	require.NoError(t, objects.ForEach(func(obj plumbing.EncodedObject) error {
+		hashes = append(hashes, obj.Hash())
+		return nil
+	}))
+	require.Len(t, hashes, 4, "3 commits and the empty tree")
+}
+
+func TestStorageAlternateName(t *testing.T) {
+	root := t.TempDir()
+	runGit(t, root, "init", "-q", "--object-format="+string(objectformat.Current()), "-b", "master", "base")
+	runGit(t, filepath.Join(root, "base"), "commit", "-q", "--allow-empty", "-m", "base")
+	runGit(t, root, "clone", "-q", "--shared", "base", "clone")
+	runGit(t, filepath.Join(root, "clone"), "commit", "-q", "--allow-empty", "-m", "clone")
+	// The alternate object directory is not necessarily named "objects".
+	store := filepath.Join(root, "store")
+	require.NoError(t, os.Rename(filepath.Join(root, "base", ".git", "objects"), store))
+	objectsDir := filepath.Join(root, "clone", ".git", "objects")
+	writeAlternates(t, objectsDir, store)
+
+	dirs, err := Resolve(objectsDir)
+	require.NoError(t, err)
+	require.Equal(t, []string{store}, dirs)
+
+	storage := filesystem.NewStorage(osfs.New(filepath.Join(root, "clone", ".git")), cache.NewObjectLRUDefault())
+	alternatesStorage := NewStorage(storage, dirs, cache.NewObjectLRUDefault())
+	repository, err := git.Open(alternatesStorage, nil)
+	require.NoError(t, err)
+	// The filesystem of the alternate has it as its objects directory whatever it is named.
+	require.Len(t, alternatesStorage.AlternateFilesystems(), 1)
+	_, err = alternatesStorage.AlternateFilesystems()[0].Stat("objects/pack")
+	require.NoError(t, err)
+	head, err := repository.Head()
+	require.NoError(t, err)
+	var messages []string
+	commits, err := repository.Log(&git.LogOptions{From: head.Hash()})
+	require.NoError(t, err)
+	require.NoError(t, commits.ForEach(func(commit *object.Commit) error {
+		messages = append(messages, strings.TrimSpace(commit.Message))
+		return nil
+	}))
+	require.Equal(t, []string{"clone", "base"}, messages)
+}
+
+func TestResolveErrors(t *testing.T) {
+	root := t.TempDir()
+	first := filepath.Join(root, "first", "objects")
+	second := filepath.Join(root, "second", "objects")
+	require.NoError(t, os.MkdirAll(first, 0755))
+	require.NoError(t, os.MkdirAll(second, 0755))
+	// The cycle is followed once, the missing directory is skipped.
+	writeAlternates(t, first, "# shared objects\n"+second+"\n"+filepath.Join(root, "missing", "objects"))
+	writeAlternates(t, second, first)
+
+	dirs, err := Resolve(first)
+	require.Equal(t, []string{second}, dirs)
+	require.ErrorContains(t, err, "alternate object directory "+filepath.Join(root, "missing", "objects"))
+	require.ErrorContains(t, err, "is unreachable")
+
+	dirs, err = Resolve(filepath.Join(root, "missing", "objects"))
+	require.NoError(t, err)
+	require.Empty(t, dirs)
+}
+
+func writeAlternates(t *testing.T, objectsDir, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(objectsDir, "info"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(objectsDir, "info", "alternates"), []byte(content+"\n"), 0644))
+}
+
+func revParse(t *testing.T, dir, revision string) string {
+	t.Helper()
+	return strings.TrimSpace(runGit(t, dir, "rev-parse", revision))
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(
+		os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_AUTHOR_NAME=gitfs", "GIT_AUTHOR_EMAIL=gitfs@example.com",
+		"GIT_COMMITTER_NAME=gitfs", "GIT_COMMITTER_EMAIL=gitfs@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	return string(output)
+}
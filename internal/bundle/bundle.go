@@ -0,0 +1,216 @@
+// Package bundle loads git bundle files, the offline archives created by `git bundle create`.
+package bundle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Signatures of the supported bundle versions.
+const (
+	v2Signature = "# v2 git bundle\n"
+	v3Signature = "# v3 git bundle\n"
+)
+
+// ErrNotBundle is returned when the file is not a git bundle.
+var ErrNotBundle = errors.New("not a git bundle")
+
+// Header is the header of the bundle preceding its packfile.
+type Header struct {
+	// Prerequisites are the commits the bundle objects depend on,
+	// the repository must have them to load the bundle.
+	Prerequisites []plumbing.Hash
+	// References are the references the bundle carries, HEAD included.
+	References []*plumbing.Reference
+}
+
+// IsBundle reports whether the file at the path is a git bundle.
+func IsBundle(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	signature := make([]byte, len(v2Signature))
+	if _, err := io.ReadFull(file, signature); err != nil {
+		return false
+	}
+	return string(signature) == v2Signature || string(signature) == v3Signature
+}
+
+// Open loads the bundle file into memory and opens the repository of it.
+func Open(path string) (*git.Repository, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open bundle: %w", err)
+	}
+	defer file.Close()
+	storage := memory.NewStorage()
+	if err := Load(storage, file); err != nil {
+		return nil, fmt.Errorf("load bundle %s: %w", path, err)
+	}
+	repository, err := git.Open(storage, nil)
+	if err != nil {
+		return nil, fmt.Errorf("open bundle repository: %w", err)
+	}
+	return repository, nil
+}
+
+// Load reads the bundle into the storage: the objects of its packfile and its references.
+// The prerequisite commits of the bundle must be in the storage already,
+// otherwise an error listing the missing ones is returned and nothing is loaded.
+// HEAD of the bundle points to the branch it is at, the same as a clone of the bundle does.
+// If the bundle has no HEAD and the storage has none either, HEAD points to the default branch.
+func Load(s storage.Storer, r io.Reader) error {
+	reader := bufio.NewReader(r)
+	header, err := ReadHeader(reader)
+	if err != nil {
+		return err
+	}
+	var missing []string
+	for _, hash := range header.Prerequisites {
+		if _, err := s.EncodedObject(plumbing.CommitObject, hash); err != nil {
+			missing = append(missing, hash.String())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("repository lacks prerequisite commits: %s", strings.Join(missing, ", "))
+	}
+	if err := packfile.UpdateObjectStorage(s, reader); err != nil {
+		return fmt.Errorf("read packfile: %w", err)
+	}
+	hasHead := false
+	for _, reference := range header.References {
+		if reference.Name() == plumbing.HEAD {
+			hasHead = true
+			reference = headReference(reference.Hash(), header.References)
+		}
+		if err := s.SetReference(reference); err != nil {
+			return fmt.Errorf("set reference %s: %w", reference.Name(), err)
+		}
+	}
+	if _, err := s.Reference(plumbing.HEAD); hasHead || err == nil || len(header.References) == 0 {
+		return nil
+	}
+	if err := s.SetReference(defaultHeadReference(s, header.References)); err != nil {
+		return fmt.Errorf("set reference %s: %w", plumbing.HEAD, err)
+	}
+	return nil
+}
+
+// ReadHeader reads the header of the bundle, leaving the reader at the start of the packfile.
+func ReadHeader(reader *bufio.Reader) (*Header, error) {
+	signature, err := reader.ReadString('\n')
+	if err != nil || (signature != v2Signature && signature != v3Signature) {
+		return nil, ErrNotBundle
+	}
+	header := &Header{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("read bundle header: %w", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return header, nil
+		case strings.HasPrefix(line, "@") && signature == v3Signature:
+			if err := checkCapability(strings.TrimPrefix(line, "@")); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(line, "-"):
+			// The prerequisite hash is followed by the optional commit subject.
+			hash, _, _ := strings.Cut(strings.TrimPrefix(line, "-"), " ")
+			if !plumbing.IsHash(hash) {
+				return nil, fmt.Errorf("invalid bundle prerequisite: %s", line)
+			}
+			header.Prerequisites = append(header.Prerequisites, plumbing.NewHash(hash))
+		default:
+			hash, name, ok := strings.Cut(line, " ")
+			if !ok || !plumbing.IsHash(hash) {
+				return nil, fmt.Errorf("invalid bundle reference: %s", line)
+			}
+			header.References = append(
+				header.References,
+				plumbing.NewHashReference(plumbing.ReferenceName(name), plumbing.NewHash(hash)),
+			)
+		}
+	}
+}
+
+// checkCapability returns an error if the capability of v3 bundle is not supported.
+func checkCapability(capability string) error {
+	key, value, _ := strings.Cut(capability, "=")
+	switch key {
+	case "object-format":
+		if value != string(objectformat.Current()) {
+			return objectformat.Mismatch("bundle", format.ObjectFormat(value))
+		}
+		return nil
+	case "filter":
+		// The objects omitted by the filter are missing from the repository, as in a partial clone.
+		return nil
+	default:
+		return fmt.Errorf("unsupported bundle capability: %s", capability)
+	}
+}
+
+// defaultHeadReference returns HEAD pointing to the default branch of the references,
+// or detached HEAD at the commit of the first reference if there are no branches.
+func defaultHeadReference(s storage.Storer, references []*plumbing.Reference) *plumbing.Reference {
+	var branches []plumbing.ReferenceName
+	for _, reference := range references {
+		if reference.Name().IsBranch() {
+			branches = append(branches, reference.Name())
+		}
+	}
+	if len(branches) > 0 {
+		return plumbing.NewSymbolicReference(plumbing.HEAD, preferredBranch(branches))
+	}
+	hash := references[0].Hash()
+	if tag, err := object.GetTag(s, hash); err == nil {
+		if commit, err := tag.Commit(); err == nil {
+			hash = commit.Hash
+		}
+	}
+	return plumbing.NewHashReference(plumbing.HEAD, hash)
+}
+
+// headReference returns HEAD pointing to the branch at the hash if there is one,
+// or detached HEAD otherwise.
+func headReference(hash plumbing.Hash, references []*plumbing.Reference) *plumbing.Reference {
+	var branches []plumbing.ReferenceName
+	for _, reference := range references {
+		if reference.Name().IsBranch() && reference.Hash() == hash {
+			branches = append(branches, reference.Name())
+		}
+	}
+	if len(branches) == 0 {
+		return plumbing.NewHashReference(plumbing.HEAD, hash)
+	}
+	return plumbing.NewSymbolicReference(plumbing.HEAD, preferredBranch(branches))
+}
+
+// preferredBranch returns the branch named as the default ones, or the first branch by name.
+func preferredBranch(branches []plumbing.ReferenceName) plumbing.ReferenceName {
+	sort.Slice(branches, func(i, j int) bool { return branches[i] < branches[j] })
+	for _, name := range branches {
+		if name == plumbing.Master || name == plumbing.Main {
+			return name
+		}
+	}
+	return branches[0]
+}
@@ -0,0 +1,123 @@
+package bundle
+
+import (
+	"bufio"
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpen(t *testing.T) {
+	repoPath, hashes := createRepository(t)
+	for _, version := range []string{"2", "3"} {
+		t.Run("v"+version, func(t *testing.T) {
+			if version == "2" && objectformat.Current() != format.SHA1 {
+				t.Skip("bundle version 2 has SHA-1 objects only")
+			}
+			path := filepath.Join(t.TempDir(), "release.bundle")
+			runGit(t, repoPath, "bundle", "create", "--version="+version, path, "--all")
+			require.True(t, IsBundle(path))
+
+			repository, err := Open(path)
+			require.NoError(t, err)
+			head, err := repository.Reference(plumbing.HEAD, false)
+			require.NoError(t, err)
+			require.Equal(t, plumbing.Master, head.Target())
+			tag, err := repository.Tag("v1.0.0")
+			require.NoError(t, err)
+			require.Equal(t, hashes[0], tag.Hash())
+			commit, err := repository.CommitObject(hashes[1])
+			require.NoError(t, err)
+			require.Equal(t, []plumbing.Hash{hashes[0]}, commit.ParentHashes)
+		})
+	}
+
+	require.False(t, IsBundle(repoPath))
+	require.False(t, IsBundle(filepath.Join(repoPath, "file")))
+}
+
+func TestLoadPrerequisites(t *testing.T) {
+	repoPath, hashes := createRepository(t)
+	path := filepath.Join(t.TempDir(), "incremental.bundle")
+	runGit(t, repoPath, "bundle", "create", path, "master~1..master")
+
+	_, err := Open(path)
+	require.ErrorContains(t, err, "repository lacks prerequisite commits: "+hashes[0].String())
+
+	// The bundle is loaded into the repository having its prerequisites.
+	base := filepath.Join(t.TempDir(), "base.bundle")
+	runGit(t, repoPath, "bundle", "create", base, "v1.0.0")
+	storage := memory.NewStorage()
+	loadFile(t, storage, base)
+	loadFile(t, storage, path)
+	repository, err := git.Open(storage, nil)
+	require.NoError(t, err)
+	head, err := repository.Head()
+	require.NoError(t, err)
+	require.Equal(t, hashes[0], head.Hash(), "HEAD of the tag bundle must be detached at the tagged commit")
+	branch, err := repository.Reference(plumbing.Master, false)
+	require.NoError(t, err)
+	require.Equal(t, hashes[1], branch.Hash())
+}
+
+func TestReadHeader(t *testing.T) {
+	other := format.SHA256
+	if objectformat.Current() == format.SHA256 {
+		other = format.SHA1
+	}
+	_, err := ReadHeader(bufio.NewReader(strings.NewReader("# v3 git bundle\n@object-format=" + string(other) + "\n\n")))
+	require.ErrorIs(t, err, objectformat.ErrUnsupported)
+	require.ErrorContains(t, err, "bundle uses "+string(other))
+	_, err = ReadHeader(bufio.NewReader(strings.NewReader("PACK")))
+	require.ErrorIs(t, err, ErrNotBundle)
+}
+
+// createRepository creates a repository with two commits on master, the first one tagged v1.0.0.
+func createRepository(t *testing.T) (string, []plumbing.Hash) {
+	t.Helper()
+	repoPath := t.TempDir()
+	repository, err := git.PlainInitWithOptions(repoPath, &git.PlainInitOptions{ObjectFormat: objectformat.Current()})
+	require.NoError(t, err)
+	worktree, err := repository.Worktree()
+	require.NoError(t, err)
+	var hashes []plumbing.Hash
+	for _, content := range []string{"first\n", "second\n"} {
+		require.NoError(t, os.WriteFile(filepath.Join(repoPath, "file"), []byte(content), 0644))
+		_, err = worktree.Add("file")
+		require.NoError(t, err)
+		hash, err := worktree.Commit(content, &git.CommitOptions{
+			Author: &object.Signature{Name: "gitfs", Email: "gitfs@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+	_, err = repository.CreateTag("v1.0.0", hashes[0], nil)
+	require.NoError(t, err)
+	return repoPath, hashes
+}
+
+func loadFile(t *testing.T, storage *memory.Storage, path string) {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	require.NoError(t, Load(storage, file))
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
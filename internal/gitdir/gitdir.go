@@ -0,0 +1,164 @@
+// Package gitdir finds the git directory of a repository the way git does.
+package gitdir
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	dotGit        = ".git"
+	headFile      = "HEAD"
+	objectsDir    = "objects"
+	commonDirFile = "commondir"
+	gitFilePrefix = "gitdir:"
+)
+
+// ErrNotFound is returned when no git directory is found.
+var ErrNotFound = errors.New("not a git repository (or any of the parent directories)")
+
+// Dir is the git directory of a repository.
+type Dir struct {
+	// Path is the git directory, for example, the .git directory of the worktree,
+	// the bare repository, or .git/worktrees/<name> of a linked worktree.
+	Path string
+	// CommonPath is the directory keeping the objects and the shared references,
+	// the git directory of the main worktree for a linked worktree, or the Path itself.
+	CommonPath string
+}
+
+// Find finds the git directory of the repository containing the path, the same as git discovers it:
+// the .git directory or the .git file pointing to the git directory, or the bare repository itself,
+// in the path or in the nearest parent directory.
+// The search stops before the directories listed in GIT_CEILING_DIRECTORIES.
+func Find(path string) (*Dir, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("absolute path %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("find git directory: %w", err)
+	}
+	if !info.IsDir() {
+		// The .git file of a linked worktree or a submodule is given itself.
+		return Resolve(path)
+	}
+	ceilings := ceilingDirs()
+	for dir := path; ; {
+		if _, err := os.Stat(filepath.Join(dir, dotGit)); err == nil {
+			return Resolve(filepath.Join(dir, dotGit))
+		}
+		if isGitDir(dir) {
+			return Resolve(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir || ceilings[parent] {
+			return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+		}
+		dir = parent
+	}
+}
+
+// Resolve returns the git directory at the path without searching for it, the same as GIT_DIR is used.
+// The path is either the git directory or the .git file pointing to it.
+func Resolve(path string) (*Dir, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("absolute path %s: %w", path, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("resolve git directory: %w", err)
+	}
+	if !info.IsDir() {
+		path, err = readGitFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !isGitDir(path) {
+		return nil, fmt.Errorf("%s: %w", path, ErrNotFound)
+	}
+	commonPath, err := readCommonDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Dir{Path: path, CommonPath: commonPath}, nil
+}
+
+// Filesystem returns the filesystem of the git directory,
+// the objects and the shared references of a linked worktree are read from the common directory.
+func (dir *Dir) Filesystem() billy.Filesystem {
+	if dir.CommonPath == dir.Path {
+		return osfs.New(dir.Path)
+	}
+	return dotgit.NewRepositoryFilesystem(osfs.New(dir.Path), osfs.New(dir.CommonPath))
+}
+
+// isGitDir reports whether the directory looks like a git directory: it has HEAD,
+// and the objects directory or the commondir file pointing to the directory having one.
+func isGitDir(dir string) bool {
+	if info, err := os.Stat(filepath.Join(dir, headFile)); err != nil || info.IsDir() {
+		return false
+	}
+	if _, err := os.Stat(filepath.Join(dir, commonDirFile)); err == nil {
+		return true
+	}
+	info, err := os.Stat(filepath.Join(dir, objectsDir))
+	return err == nil && info.IsDir()
+}
+
+// readGitFile returns the git directory the .git file points to with the "gitdir: <path>" line.
+// The relative path is relative to the directory of the file.
+func readGitFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read git file: %w", err)
+	}
+	line, _, _ := strings.Cut(string(content), "\n")
+	gitDir, ok := strings.CutPrefix(line, gitFilePrefix)
+	if !ok {
+		return "", fmt.Errorf("%s: invalid git file, no %q prefix", path, gitFilePrefix)
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return filepath.Clean(gitDir), nil
+}
+
+// readCommonDir returns the directory the commondir file of the git directory points to,
+// or the git directory itself if it has no commondir file.
+// The relative path is relative to the git directory.
+func readCommonDir(gitDir string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(gitDir, commonDirFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return gitDir, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("read commondir: %w", err)
+	}
+	commonDir := strings.TrimSpace(string(content))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return filepath.Clean(commonDir), nil
+}
+
+// ceilingDirs returns the directories listed in GIT_CEILING_DIRECTORIES.
+func ceilingDirs() map[string]bool {
+	dirs := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("GIT_CEILING_DIRECTORIES")) {
+		if dir != "" {
+			dirs[filepath.Clean(dir)] = true
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,101 @@
+package gitdir
+
+import (
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	mainPath := filepath.Join(root, "main")
+	runGit(t, root, "init", "-q", "--object-format="+string(objectformat.Current()), "-b", "master", mainPath)
+	runGit(t, mainPath, "commit", "-q", "--allow-empty", "-m", "first")
+	runGit(t, mainPath, "worktree", "add", "-q", "-b", "feature", filepath.Join(root, "linked"))
+	runGit(t, root, "init", "-q", "--object-format="+string(objectformat.Current()), "--bare", filepath.Join(root, "bare.git"))
+	require.NoError(t, os.MkdirAll(filepath.Join(mainPath, "sub", "dir"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "moved"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "moved", ".git"), []byte("gitdir: ../main/.git\n"), 0644))
+
+	mainDir := &Dir{Path: filepath.Join(mainPath, ".git"), CommonPath: filepath.Join(mainPath, ".git")}
+	linkedDir := &Dir{Path: filepath.Join(mainPath, ".git", "worktrees", "linked"), CommonPath: filepath.Join(mainPath, ".git")}
+	tests := map[string]*Dir{
+		"main":                 mainDir,
+		"main/sub/dir":         mainDir,
+		"main/.git":            mainDir,
+		"main/.git/refs/heads": mainDir,
+		"linked":               linkedDir,
+		"linked/.git":          linkedDir,
+		"moved":                mainDir,
+		"bare.git":             {Path: filepath.Join(root, "bare.git"), CommonPath: filepath.Join(root, "bare.git")},
+		"bare.git/refs/heads":  {Path: filepath.Join(root, "bare.git"), CommonPath: filepath.Join(root, "bare.git")},
+	}
+	for path, expected := range tests {
+		dir, err := Find(filepath.Join(root, path))
+		require.NoError(t, err, path)
+		require.Equal(t, expected, dir, path)
+	}
+
+	t.Setenv("GIT_CEILING_DIRECTORIES", mainPath)
+	_, err = Find(filepath.Join(mainPath, "sub", "dir"))
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = Find(root)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestResolve(t *testing.T) {
+	root := t.TempDir()
+	mainPath := filepath.Join(root, "main")
+	runGit(t, root, "init", "-q", "--object-format="+string(objectformat.Current()), "-b", "master", mainPath)
+	_, err := Resolve(mainPath)
+	require.ErrorIs(t, err, ErrNotFound, "the worktree is not the git directory")
+
+	require.NoError(t, os.WriteFile(filepath.Join(root, "invalid"), []byte("main/.git\n"), 0644))
+	_, err = Resolve(filepath.Join(root, "invalid"))
+	require.ErrorContains(t, err, "invalid git file")
+}
+
+func TestFilesystem(t *testing.T) {
+	root := t.TempDir()
+	mainPath := filepath.Join(root, "main")
+	runGit(t, root, "init", "-q", "--object-format="+string(objectformat.Current()), "-b", "master", mainPath)
+	runGit(t, mainPath, "commit", "-q", "--allow-empty", "-m", "first")
+	runGit(t, mainPath, "worktree", "add", "-q", "-b", "feature", filepath.Join(root, "linked"))
+	runGit(t, filepath.Join(root, "linked"), "commit", "-q", "--allow-empty", "-m", "second")
+
+	dir, err := Find(filepath.Join(root, "linked"))
+	require.NoError(t, err)
+	repository, err := git.Open(filesystem.NewStorage(dir.Filesystem(), cache.NewObjectLRUDefault()), nil)
+	require.NoError(t, err)
+	head, err := repository.Head()
+	require.NoError(t, err)
+	require.Equal(t, plumbing.NewBranchReferenceName("feature"), head.Name())
+	commit, err := repository.CommitObject(head.Hash())
+	require.NoError(t, err)
+	require.Equal(t, "second\n", commit.Message)
+	_, err = repository.Reference(plumbing.Master, false)
+	require.NoError(t, err, "the branches are shared with the main worktree")
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(
+		os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_AUTHOR_NAME=gitfs", "GIT_AUTHOR_EMAIL=gitfs@example.com",
+		"GIT_COMMITTER_NAME=gitfs", "GIT_COMMITTER_EMAIL=gitfs@example.com",
+	)
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
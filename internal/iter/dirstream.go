@@ -1,6 +1,7 @@
 package iter
 
 import (
+	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"syscall"
 )
@@ -34,3 +35,37 @@ func (adapter *DirStreamAdapter[T]) Next() (fuse.DirEntry, syscall.Errno) {
 }
 
 func (adapter *DirStreamAdapter[T]) Close() { adapter.iter.Close() }
+
+// ConcatDirStream streams the entries of the given directory streams one after another.
+type ConcatDirStream struct {
+	streams []fs.DirStream
+}
+
+func NewConcatDirStream(streams ...fs.DirStream) *ConcatDirStream {
+	return &ConcatDirStream{streams: streams}
+}
+
+func (stream *ConcatDirStream) HasNext() bool {
+	for len(stream.streams) > 0 {
+		if stream.streams[0].HasNext() {
+			return true
+		}
+		stream.streams[0].Close()
+		stream.streams = stream.streams[1:]
+	}
+	return false
+}
+
+func (stream *ConcatDirStream) Next() (fuse.DirEntry, syscall.Errno) {
+	if len(stream.streams) == 0 {
+		return fuse.DirEntry{}, syscall.ENOENT
+	}
+	return stream.streams[0].Next()
+}
+
+func (stream *ConcatDirStream) Close() {
+	for _, s := range stream.streams {
+		s.Close()
+	}
+	stream.streams = nil
+}
@@ -0,0 +1,71 @@
+// Package objectformat checks that repositories use the object format gitfs is built for.
+package objectformat
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5/config"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/hash"
+)
+
+const (
+	extensionsSection = "extensions"
+	objectFormatKey   = "objectformat"
+)
+
+// ErrUnsupported is returned when the repository objects are hashed with another algorithm
+// than gitfs is built for.
+var ErrUnsupported = errors.New("unsupported object format")
+
+// configStorer is implemented by storages keeping the repository config.
+type configStorer interface {
+	Config() (*config.Config, error)
+}
+
+// Current returns the object format gitfs is built for:
+// SHA-256 if it is built with the sha256 tag, SHA-1 otherwise.
+// The format is fixed at build time, since go-git hashes have a fixed size.
+// The SHA-256 build can't clone or fetch repositories, since go-git speaks the protocol of SHA-1 repositories only.
+func Current() format.ObjectFormat {
+	if hash.Size == sha256.Size {
+		return format.SHA256
+	}
+	return format.SHA1
+}
+
+// Check returns an error if the extensions.objectFormat of the repository config
+// is not the object format gitfs is built for.
+// The option is read from the raw config, since go-git parses it only when it is built for SHA-256.
+func Check(storage configStorer) error {
+	cfg, err := storage.Config()
+	if err != nil {
+		return fmt.Errorf("repository: config: %w", err)
+	}
+	objectFormat := format.ObjectFormat(cfg.Raw.Section(extensionsSection).Option(objectFormatKey))
+	if objectFormat == "" {
+		objectFormat = format.DefaultObjectFormat
+	}
+	if objectFormat == Current() {
+		return nil
+	}
+	return Mismatch("repository", objectFormat)
+}
+
+// Mismatch returns the error telling that the subject uses another object format than gitfs is built for,
+// and which build of gitfs mounts it.
+func Mismatch(subject string, objectFormat format.ObjectFormat) error {
+	return fmt.Errorf(
+		"%w: %s uses %s, but this gitfs is built for %s, %s repositories are mounted by %s",
+		ErrUnsupported, subject, objectFormat, Current(), objectFormat, Build(objectFormat),
+	)
+}
+
+// Build returns the gitfs build mounting the repositories of the object format.
+func Build(objectFormat format.ObjectFormat) string {
+	if objectFormat == format.SHA256 {
+		return "the gitfs-sha256 release build, or gitfs built with -tags sha256"
+	}
+	return "the gitfs release build, or gitfs built without the sha256 tag"
+}
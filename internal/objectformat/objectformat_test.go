@@ -0,0 +1,29 @@
+package objectformat
+
+import (
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/stretchr/testify/require"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	for _, objectFormat := range []format.ObjectFormat{format.SHA1, format.SHA256} {
+		repoPath := filepath.Join(t.TempDir(), "repo.git")
+		output, err := exec.Command("git", "init", "-q", "--bare", "--object-format="+string(objectFormat), repoPath).CombinedOutput()
+		require.NoError(t, err, string(output))
+
+		err = Check(filesystem.NewStorage(osfs.New(repoPath), cache.NewObjectLRUDefault()))
+		if objectFormat == Current() {
+			require.NoError(t, err, objectFormat)
+			continue
+		}
+		require.ErrorIs(t, err, ErrUnsupported, objectFormat)
+		require.ErrorContains(t, err, "repository uses "+string(objectFormat), objectFormat)
+		require.ErrorContains(t, err, Build(objectFormat), objectFormat)
+	}
+}
@@ -0,0 +1,147 @@
+// Package refindex indexes git references by the segments of their names.
+package refindex
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const separator = "/"
+
+// Index is a trie of the repository references keyed by the segments of their names,
+// for example, "refs/tags/release/v1.0.0" is stored as "refs", "tags", "release" and "v1.0.0" nodes.
+// The trie is built once and rebuilt only after it is invalidated, for example, by a watcher
+// of the references or after a fetch, so looking up a name does not read the references.
+// The references changed without notice are seen once the trie gets older than the max age, if it is set.
+type Index struct {
+	storer storer.ReferenceStorer
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	root    *Node
+	builtAt time.Time
+}
+
+// Node is a node of the reference trie. The nodes are never modified after the trie is built,
+// so they can be used without locking while the index is rebuilt.
+type Node struct {
+	reference *plumbing.Reference
+	children  map[string]*Node
+}
+
+// Child is a named child of the trie node.
+type Child struct {
+	Name string
+	Node *Node
+}
+
+// New creates a new Index of the storer references.
+// If the maxAge is positive, the trie is rebuilt once it is older than it even if it is not invalidated.
+func New(storer storer.ReferenceStorer, maxAge time.Duration) *Index {
+	return &Index{storer: storer, maxAge: maxAge}
+}
+
+// Invalidate makes the index rebuild the trie on the next lookup.
+// It is meant to be called after the references change.
+func (index *Index) Invalidate() {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.root = nil
+}
+
+// Lookup returns the trie node of the reference name or of the prefix of reference names,
+// for example, "refs/tags/release" if there is "refs/tags/release/v1.0.0" reference.
+// It returns nil if there are no references with the name.
+func (index *Index) Lookup(name string) (*Node, error) {
+	root, err := index.Root()
+	if err != nil {
+		return nil, err
+	}
+	node := root
+	for _, segment := range strings.Split(name, separator) {
+		node = node.Child(segment)
+		if node == nil {
+			return nil, nil
+		}
+	}
+	return node, nil
+}
+
+// Root returns the root of the trie, building the trie if it is invalidated or expired.
+// The root is a new node each time the trie is rebuilt, so it identifies the state of the references,
+// for example, to cache data derived from them.
+func (index *Index) Root() (*Node, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if index.root != nil && (index.maxAge <= 0 || time.Since(index.builtAt) < index.maxAge) {
+		return index.root, nil
+	}
+	root, err := build(index.storer)
+	if err != nil {
+		return nil, err
+	}
+	index.root = root
+	index.builtAt = time.Now()
+	return root, nil
+}
+
+func build(storer storer.ReferenceStorer) (*Node, error) {
+	refs, err := storer.IterReferences()
+	if err != nil {
+		return nil, fmt.Errorf("iterate references: %w", err)
+	}
+	root := &Node{}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		node := root
+		for _, segment := range strings.Split(ref.Name().String(), separator) {
+			child, ok := node.children[segment]
+			if !ok {
+				child = &Node{}
+				if node.children == nil {
+					node.children = make(map[string]*Node)
+				}
+				node.children[segment] = child
+			}
+			node = child
+		}
+		node.reference = ref
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("iterate references: %w", err)
+	}
+	return root, nil
+}
+
+// Reference returns the reference with the name ending at the node,
+// or nil if the node is only a prefix of reference names.
+func (node *Node) Reference() *plumbing.Reference {
+	return node.reference
+}
+
+// Child returns the child node with the name, or nil if there is no such child.
+func (node *Node) Child(name string) *Node {
+	return node.children[name]
+}
+
+// NumChildren returns the number of the child nodes.
+func (node *Node) NumChildren() int {
+	return len(node.children)
+}
+
+// Children returns the child nodes sorted by their names.
+func (node *Node) Children() []Child {
+	children := make([]Child, 0, len(node.children))
+	for name, child := range node.children {
+		children = append(children, Child{Name: name, Node: child})
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].Name < children[j].Name
+	})
+	return children
+}
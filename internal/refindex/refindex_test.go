@@ -0,0 +1,102 @@
+package refindex
+
+import (
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testHash = plumbing.NewHash("3991e5a5a1d1a0f1d5a07c6bd3bc4f93a8cd7a8c")
+
+func TestIndexLookup(t *testing.T) {
+	storers := map[string]storage.Storer{
+		"memory":     memory.NewStorage(),
+		"filesystem": filesystem.NewStorage(osfs.New(t.TempDir()), cache.NewObjectLRUDefault()),
+	}
+	for name, storer := range storers {
+		t.Run(name, func(t *testing.T) {
+			setReference(t, storer, "refs/heads/master")
+			setReference(t, storer, "refs/tags/release/2024/v1.2.3")
+			setReference(t, storer, "refs/tags/release/2024/v1.2.4")
+			index := New(storer, 0)
+
+			node, err := index.Lookup("refs/heads/master")
+			require.NoError(t, err)
+			require.NotNil(t, node)
+			require.Equal(t, plumbing.ReferenceName("refs/heads/master"), node.Reference().Name())
+			require.Equal(t, testHash, node.Reference().Hash())
+
+			node, err = index.Lookup("refs/tags/release/2024")
+			require.NoError(t, err)
+			require.NotNil(t, node)
+			require.Nil(t, node.Reference())
+			require.Equal(t, []string{"v1.2.3", "v1.2.4"}, childNames(node))
+
+			// Prefixes of segment names are not prefixes of reference names.
+			node, err = index.Lookup("refs/heads/mas")
+			require.NoError(t, err)
+			require.Nil(t, node)
+
+			node, err = index.Lookup("refs/tags/release/2024/v1.2.3/extra")
+			require.NoError(t, err)
+			require.Nil(t, node)
+		})
+	}
+}
+
+func TestIndexInvalidate(t *testing.T) {
+	storer := filesystem.NewStorage(osfs.New(t.TempDir()), cache.NewObjectLRUDefault())
+	setReference(t, storer, "refs/heads/master")
+	index := New(storer, 0)
+
+	first, err := index.Lookup("refs/heads")
+	require.NoError(t, err)
+	setReference(t, storer, "refs/heads/feature")
+	second, err := index.Lookup("refs/heads")
+	require.NoError(t, err)
+	require.Same(t, first, second, "the index must not be rebuilt until it is invalidated")
+
+	index.Invalidate()
+	node, err := index.Lookup("refs/heads")
+	require.NoError(t, err)
+	require.Equal(t, []string{"feature", "master"}, childNames(node))
+
+	require.NoError(t, storer.RemoveReference("refs/heads/feature"))
+	index.Invalidate()
+	node, err = index.Lookup("refs/heads")
+	require.NoError(t, err)
+	require.Equal(t, []string{"master"}, childNames(node))
+}
+
+func TestIndexMaxAge(t *testing.T) {
+	storer := memory.NewStorage()
+	setReference(t, storer, "refs/heads/master")
+	index := New(storer, time.Millisecond)
+
+	_, err := index.Lookup("refs/heads")
+	require.NoError(t, err)
+	setReference(t, storer, "refs/heads/feature")
+	time.Sleep(2 * time.Millisecond)
+	node, err := index.Lookup("refs/heads")
+	require.NoError(t, err)
+	require.Equal(t, []string{"feature", "master"}, childNames(node))
+}
+
+func setReference(t *testing.T, storer storage.Storer, name string) {
+	t.Helper()
+	require.NoError(t, storer.SetReference(plumbing.NewHashReference(plumbing.ReferenceName(name), testHash)))
+}
+
+func childNames(node *Node) []string {
+	var names []string
+	for _, child := range node.Children() {
+		names = append(names, child.Name)
+	}
+	return names
+}
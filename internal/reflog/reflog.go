@@ -0,0 +1,137 @@
+package reflog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned when the reference has no reflog.
+var ErrNotFound = errors.New("reflog not found")
+
+const logsDir = "logs"
+
+// Entry is a single record of a reference log.
+type Entry struct {
+	Old       plumbing.Hash
+	New       plumbing.Hash
+	Committer object.Signature
+	Message   string
+}
+
+// filesystemStorer is implemented by storages keeping the repository on a filesystem.
+// Reflogs are not accessible through go-git storer interfaces, so they are read from the filesystem directly.
+type filesystemStorer interface {
+	Filesystem() billy.Filesystem
+}
+
+// Read reads the reflog of the reference from the storer.
+// Entries are returned from the newest to the oldest, the same order as `git reflog` shows them,
+// so the index of the entry is the n of the "<ref>@{n}" notation.
+// It returns ErrNotFound if the storer doesn't keep reflogs or the reference has no reflog.
+func Read(storer storage.Storer, name plumbing.ReferenceName) ([]Entry, error) {
+	fsStorer, ok := storer.(filesystemStorer)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	file, err := fsStorer.Filesystem().Open(path.Join(logsDir, name.String()))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open reflog: %w", err)
+	}
+	defer file.Close()
+
+	entries, err := Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("decode reflog: %w", err)
+	}
+	return entries, nil
+}
+
+// List returns sorted names of the references starting with the prefix that have reflogs.
+// It returns no names if the storer doesn't keep reflogs.
+func List(storer storage.Storer, prefix string) ([]plumbing.ReferenceName, error) {
+	fsStorer, ok := storer.(filesystemStorer)
+	if !ok {
+		return nil, nil
+	}
+	var names []plumbing.ReferenceName
+	fs := fsStorer.Filesystem()
+	err := util.Walk(fs, logsDir, func(filename string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		name := strings.TrimPrefix(filepath.ToSlash(filename), logsDir+"/")
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, plumbing.ReferenceName(name))
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("walk reflogs: %w", err)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names, nil
+}
+
+// Decode decodes the reflog file content.
+// Entries are returned from the newest to the oldest.
+func Decode(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		entry, err := decodeEntry(line)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// decodeEntry decodes the reflog line of the following format:
+// <old hash> <new hash> <name> <<email>> <timestamp> <timezone>\t<message>
+func decodeEntry(line []byte) (Entry, error) {
+	header, message, _ := bytes.Cut(line, []byte{'\t'})
+	fields := bytes.SplitN(header, []byte{' '}, 3)
+	if len(fields) != 3 || !plumbing.IsHash(string(fields[0])) || !plumbing.IsHash(string(fields[1])) {
+		return Entry{}, fmt.Errorf("malformed reflog entry: %q", line)
+	}
+	entry := Entry{
+		Old:     plumbing.NewHash(string(fields[0])),
+		New:     plumbing.NewHash(string(fields[1])),
+		Message: strings.TrimSpace(string(message)),
+	}
+	entry.Committer.Decode(fields[2])
+	return entry, nil
+}
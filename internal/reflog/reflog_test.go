@@ -0,0 +1,46 @@
+package reflog
+
+import (
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/hash"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	var (
+		zero   = fullHash("0000000000000000000000000000000000000000")
+		first  = fullHash("3991e5a92b70e6a4e91ce48d2165a92b8b056cdd")
+		second = fullHash("3d97e28b20f8babc2182f2e67ba7d51397dd0ff5")
+	)
+	content := "" +
+		zero + " " + first + " " +
+		"Dmitriy Smotrov <dsxack@gmail.com> 1680125446 +0400\tcommit (initial): init commit\n" +
+		first + " " + second + " " +
+		"Dmitriy Smotrov <dsxack@gmail.com> 1680125522 +0400\tcommit: second commit\n"
+
+	entries, err := Decode(strings.NewReader(content))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Equal(t, plumbing.NewHash(first), entries[0].Old)
+	require.Equal(t, plumbing.NewHash(second), entries[0].New)
+	require.Equal(t, "commit: second commit", entries[0].Message)
+	require.Equal(t, "Dmitriy Smotrov", entries[0].Committer.Name)
+	require.Equal(t, "dsxack@gmail.com", entries[0].Committer.Email)
+	require.Equal(t, int64(1680125522), entries[0].Committer.When.Unix())
+
+	require.Equal(t, plumbing.ZeroHash, entries[1].Old)
+	require.Equal(t, "commit (initial): init commit", entries[1].Message)
+}
+
+// fullHash pads the SHA-1 hex hash to the size of the hashes gitfs is built for.
+func fullHash(sha1 string) string {
+	return sha1 + strings.Repeat("0", hash.HexSize-len(sha1))
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	_, err := Decode(strings.NewReader("malformed line\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,76 @@
+// Package refwatch watches the references of a git directory for changes.
+package refwatch
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	refsDir        = "refs"
+	packedRefsFile = "packed-refs"
+	headFile       = "HEAD"
+	lockFileSuffix = ".lock"
+)
+
+// Watcher reports changes of the loose references, the packed references and HEAD of git directories.
+// A linked worktree has two of them: its own one keeping HEAD,
+// and the common one keeping the references shared by all worktrees.
+type Watcher struct {
+	gitDirs []string
+	changes chan struct{}
+	done    chan struct{}
+	closer  func() error
+}
+
+// Changes returns the channel receiving a value after the references change.
+// Changes happening before the value is received are coalesced into it,
+// so a burst of updates, for example, a push of many branches, is reported once or twice.
+func (watcher *Watcher) Changes() <-chan struct{} {
+	return watcher.changes
+}
+
+// Close stops watching. The changes channel is closed once the watcher has stopped.
+func (watcher *Watcher) Close() error {
+	err := watcher.closer()
+	<-watcher.done
+	return err
+}
+
+func (watcher *Watcher) notify() {
+	select {
+	case watcher.changes <- struct{}{}:
+	default:
+	}
+}
+
+// isReferenceFile reports whether the file in the git directories is a reference file.
+// Lock files are written by git before they are renamed to the reference files, so they are skipped.
+func (watcher *Watcher) isReferenceFile(path string) bool {
+	if strings.HasSuffix(path, lockFileSuffix) {
+		return false
+	}
+	for _, gitDir := range watcher.gitDirs {
+		rel, err := filepath.Rel(gitDir, path)
+		if err != nil {
+			continue
+		}
+		if rel == packedRefsFile || rel == headFile || strings.HasPrefix(rel, refsDir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueDirs returns the cleaned directories without duplicates.
+func uniqueDirs(dirs []string) []string {
+	var unique []string
+	for _, dir := range dirs {
+		dir = filepath.Clean(dir)
+		if !slices.Contains(unique, dir) {
+			unique = append(unique, dir)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,159 @@
+package refwatch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"unsafe"
+)
+
+const (
+	fileEvents = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM | unix.IN_CREATE | unix.IN_DELETE
+	dirEvents  = fileEvents | unix.IN_DELETE_SELF
+)
+
+// inotify keeps the watch descriptors of the watched directories.
+type inotify struct {
+	// fd is kept besides the file, since getting the descriptor of the file makes it blocking.
+	fd   int
+	file *os.File
+
+	mu   sync.Mutex
+	dirs map[int]string
+}
+
+// New starts watching the references of the git directories with inotify.
+// The refs directories are watched recursively, the directories created later are watched as they appear.
+func New(gitDirs ...string) (*Watcher, error) {
+	gitDirs = uniqueDirs(gitDirs)
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+	// The non-blocking descriptor is polled by the runtime, so closing the file interrupts the read.
+	notify := &inotify{fd: fd, file: os.NewFile(uintptr(fd), "inotify"), dirs: make(map[int]string)}
+	for _, gitDir := range gitDirs {
+		if err := notify.add(gitDir, fileEvents); err != nil {
+			_ = notify.file.Close()
+			return nil, err
+		}
+		if err := notify.addTree(filepath.Join(gitDir, refsDir)); err != nil {
+			_ = notify.file.Close()
+			return nil, err
+		}
+	}
+	watcher := &Watcher{
+		gitDirs: gitDirs,
+		changes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		closer:  notify.file.Close,
+	}
+	go watcher.run(notify)
+	return watcher, nil
+}
+
+func (notify *inotify) add(dir string, mask uint32) error {
+	notify.mu.Lock()
+	defer notify.mu.Unlock()
+	wd, err := unix.InotifyAddWatch(notify.fd, dir, mask)
+	if err != nil {
+		return fmt.Errorf("inotify watch %s: %w", dir, err)
+	}
+	notify.dirs[wd] = dir
+	return nil
+}
+
+// addTree watches the directory and all its subdirectories.
+// Missing directories are skipped, they may be removed by git while they are walked.
+func (notify *inotify) addTree(root string) error {
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if err := notify.add(path, dirEvents); err != nil && !errors.Is(err, unix.ENOENT) {
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (notify *inotify) dir(wd int) (string, bool) {
+	notify.mu.Lock()
+	defer notify.mu.Unlock()
+	dir, ok := notify.dirs[wd]
+	return dir, ok
+}
+
+func (notify *inotify) remove(wd int) {
+	notify.mu.Lock()
+	defer notify.mu.Unlock()
+	delete(notify.dirs, wd)
+}
+
+func (watcher *Watcher) run(notify *inotify) {
+	defer close(watcher.done)
+	defer close(watcher.changes)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := notify.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				slog.Default().Error("Error read reference changes", slog.String("error", err.Error()))
+			}
+			return
+		}
+		changed := false
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameBytes := buf[offset+unix.SizeofInotifyEvent : offset+unix.SizeofInotifyEvent+int(event.Len)]
+			name := string(bytes.TrimRight(nameBytes, "\x00"))
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			if event.Mask&unix.IN_Q_OVERFLOW != 0 {
+				changed = true
+				continue
+			}
+			if event.Mask&(unix.IN_DELETE_SELF|unix.IN_IGNORED) != 0 {
+				notify.remove(int(event.Wd))
+				continue
+			}
+			dir, ok := notify.dir(int(event.Wd))
+			if !ok {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if event.Mask&unix.IN_ISDIR != 0 {
+				if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 && watcher.isReferenceFile(path) {
+					// The references could be written into the new directory before it is watched.
+					if err := notify.addTree(path); err != nil {
+						slog.Default().Error("Error watch references", slog.String("error", err.Error()))
+					}
+					changed = true
+				}
+				continue
+			}
+			if watcher.isReferenceFile(path) {
+				changed = true
+			}
+		}
+		if changed {
+			watcher.notify()
+		}
+	}
+}
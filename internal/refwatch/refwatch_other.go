@@ -0,0 +1,13 @@
+//go:build !linux
+
+package refwatch
+
+import (
+	"errors"
+	"fmt"
+)
+
+// New returns an error, watching references is supported on Linux only.
+func New(_ ...string) (*Watcher, error) {
+	return nil, fmt.Errorf("watch references: %w", errors.ErrUnsupported)
+}
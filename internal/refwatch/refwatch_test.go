@@ -0,0 +1,117 @@
+//go:build linux
+
+package refwatch
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const changeTimeout = 5 * time.Second
+
+func TestWatcher(t *testing.T) {
+	gitDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(gitDir, "refs", "heads"), 0755))
+	watcher, err := New(gitDir)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	changes := []struct {
+		name   string
+		change func() error
+	}{
+		{"loose reference", func() error {
+			return writeFile(gitDir, "refs/heads/master")
+		}},
+		{"reference in new directory", func() error {
+			return writeFile(gitDir, "refs/heads/feature/nested/foo")
+		}},
+		{"reference in watched new directory", func() error {
+			return writeFile(gitDir, "refs/heads/feature/bar")
+		}},
+		{"renamed lock file", func() error {
+			if err := writeFile(gitDir, "refs/heads/master.lock"); err != nil {
+				return err
+			}
+			return os.Rename(filepath.Join(gitDir, "refs/heads/master.lock"), filepath.Join(gitDir, "refs/heads/master"))
+		}},
+		{"removed reference", func() error {
+			return os.Remove(filepath.Join(gitDir, "refs/heads/feature/bar"))
+		}},
+		{"packed references", func() error {
+			return writeFile(gitDir, "packed-refs")
+		}},
+		{"HEAD", func() error {
+			return writeFile(gitDir, "HEAD")
+		}},
+	}
+	for _, change := range changes {
+		name := change.name
+		require.NoError(t, change.change(), name)
+		select {
+		case <-watcher.Changes():
+		case <-time.After(changeTimeout):
+			t.Fatalf("change of %s is not reported", name)
+		}
+		drain(watcher)
+	}
+
+	// Files other than references are not watched.
+	require.NoError(t, writeFile(gitDir, "index"))
+	require.NoError(t, writeFile(gitDir, "refs/heads/other.lock"))
+	select {
+	case <-watcher.Changes():
+		t.Fatal("change of non-reference files is reported")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, watcher.Close())
+	_, ok := <-watcher.Changes()
+	require.False(t, ok, "changes must be closed after the watcher is closed")
+}
+
+func TestWatcherLinkedWorktree(t *testing.T) {
+	commonDir := t.TempDir()
+	gitDir := filepath.Join(commonDir, "worktrees", "linked")
+	require.NoError(t, os.MkdirAll(gitDir, 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(commonDir, "refs", "heads"), 0755))
+	watcher, err := New(gitDir, commonDir, commonDir)
+	require.NoError(t, err)
+	defer watcher.Close()
+
+	for _, path := range []string{
+		filepath.Join(gitDir, "HEAD"),
+		filepath.Join(commonDir, "refs/heads/master"),
+		filepath.Join(commonDir, "packed-refs"),
+	} {
+		require.NoError(t, writeFile(filepath.Dir(path), filepath.Base(path)), path)
+		select {
+		case <-watcher.Changes():
+		case <-time.After(changeTimeout):
+			t.Fatalf("change of %s is not reported", path)
+		}
+		drain(watcher)
+	}
+}
+
+func writeFile(gitDir, name string) error {
+	path := filepath.Join(gitDir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte("3991e5a5a1d1a0f1d5a07c6bd3bc4f93a8cd7a8c\n"), 0644)
+}
+
+// drain skips the changes reported by the events of the same change, for example, a create and a write.
+func drain(watcher *Watcher) {
+	for {
+		select {
+		case <-watcher.Changes():
+		case <-time.After(50 * time.Millisecond):
+			return
+		}
+	}
+}
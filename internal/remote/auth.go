@@ -0,0 +1,269 @@
+package remote
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// tokenUsername is the username sent with the token if no username is given.
+// Git hosting services accept personal access tokens as the password of any username.
+const tokenUsername = "git"
+
+// Credentials are the credentials given explicitly to authenticate to the remote repository.
+type Credentials struct {
+	// SSHKeyFile is the private key file authenticating SSH URLs, the SSH agent is used if it is empty.
+	SSHKeyFile string
+	// SSHKeyPassphrase decrypts the SSHKeyFile if it is encrypted.
+	SSHKeyPassphrase string
+	// Username and Password authenticate HTTP URLs with the basic authentication.
+	Username string
+	Password string
+	// Token authenticates HTTP URLs as the password of the Username.
+	Token string
+	// CredentialHelper asks the git credential helpers for the credentials of HTTP URLs up front,
+	// otherwise they are asked only after the remote rejects the request, see NewReauth.
+	CredentialHelper bool
+}
+
+// NewAuth returns the authentication to the remote repository at the URL.
+// SSH URLs are authenticated with the key file, or with the SSH agent.
+// HTTP URLs are authenticated with the first credentials found in the given credentials,
+// the password in the URL itself, the netrc file and, if asked, the git credential helpers.
+// Nothing is ever prompted for, so the authentication works in daemon mode without a terminal.
+// It returns nil if the repository is accessed anonymously.
+func NewAuth(ctx context.Context, url string, credentials Credentials) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse url %s: %w", url, err)
+	}
+	switch endpoint.Protocol {
+	case "ssh":
+		return newSSHAuth(endpoint, credentials)
+	case "http", "https":
+		return newHTTPAuth(ctx, endpoint, credentials)
+	default:
+		return nil, nil
+	}
+}
+
+func newSSHAuth(endpoint *transport.Endpoint, credentials Credentials) (transport.AuthMethod, error) {
+	user := endpoint.User
+	if user == "" {
+		user = ssh.DefaultUsername
+	}
+	if credentials.SSHKeyFile != "" {
+		auth, err := ssh.NewPublicKeysFromFile(user, credentials.SSHKeyFile, credentials.SSHKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("ssh key %s: %w", credentials.SSHKeyFile, err)
+		}
+		return auth, nil
+	}
+	auth, err := ssh.NewSSHAgentAuth(user)
+	if err != nil {
+		return nil, fmt.Errorf("ssh agent: %w", err)
+	}
+	return auth, nil
+}
+
+func newHTTPAuth(ctx context.Context, endpoint *transport.Endpoint, credentials Credentials) (transport.AuthMethod, error) {
+	switch {
+	case credentials.Token != "":
+		username := credentials.Username
+		if username == "" {
+			username = tokenUsername
+		}
+		return &http.BasicAuth{Username: username, Password: credentials.Token}, nil
+	case credentials.Username != "" || credentials.Password != "":
+		return &http.BasicAuth{Username: credentials.Username, Password: credentials.Password}, nil
+	case endpoint.Password != "":
+		// The transport authenticates with the user information of the URL itself.
+		return nil, nil
+	}
+	auth, err := netrcAuth(netrcPath(), endpoint.Host)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		return auth, nil
+	}
+	if !credentials.CredentialHelper {
+		return nil, nil
+	}
+	if auth := credentialHelperAuth(ctx, endpoint); auth != nil {
+		return auth, nil
+	}
+	return nil, nil
+}
+
+// NewReauth returns the function asking the git credential helpers for the credentials of the URL
+// after the remote rejects the request, see Options.Reauth. The helpers are asked again on each rejection,
+// so the credentials they refresh, such as expiring tokens, are picked up by the long-running fetches.
+// It returns nil if the URL is not an HTTP URL, or the credentials are given explicitly or in the URL.
+func NewReauth(url string, credentials Credentials) func(ctx context.Context) transport.AuthMethod {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil || (endpoint.Protocol != "http" && endpoint.Protocol != "https") {
+		return nil
+	}
+	if credentials.Token != "" || credentials.Username != "" || credentials.Password != "" || endpoint.Password != "" {
+		return nil
+	}
+	return func(ctx context.Context) transport.AuthMethod {
+		auth := credentialHelperAuth(ctx, endpoint)
+		if auth == nil {
+			return nil
+		}
+		return auth
+	}
+}
+
+// netrcPath returns the path of the netrc file, $NETRC or ~/.netrc.
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// netrcAuth returns the credentials of the host from the netrc file,
+// or nil if the file is missing or has no credentials of the host.
+func netrcAuth(path, host string) (*http.BasicAuth, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read netrc: %w", err)
+	}
+	var (
+		found, matched bool
+		auth           http.BasicAuth
+		fields         = netrcFields(content)
+	)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine", "default":
+			if found {
+				return &auth, nil
+			}
+			if fields[i] == "default" {
+				matched = true
+			} else {
+				i++
+				matched = i < len(fields) && fields[i] == host
+			}
+			auth = http.BasicAuth{}
+		case "login", "password", "account":
+			i++
+			if !matched || i >= len(fields) {
+				continue
+			}
+			found = true
+			switch fields[i-1] {
+			case "login":
+				auth.Username = fields[i]
+			case "password":
+				auth.Password = fields[i]
+			}
+		}
+	}
+	if found {
+		return &auth, nil
+	}
+	return nil, nil
+}
+
+// netrcFields splits the netrc file into fields, skipping the macro definitions,
+// which last until an empty line.
+func netrcFields(content []byte) []string {
+	var fields []string
+	inMacro := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.Fields(scanner.Text())
+		if inMacro {
+			inMacro = len(line) > 0
+			continue
+		}
+		for i, field := range line {
+			if field == "macdef" {
+				inMacro = true
+				line = line[:i]
+				break
+			}
+		}
+		fields = append(fields, line...)
+	}
+	return fields
+}
+
+// credentialHelperAuth asks the git credential helpers for the credentials of the endpoint
+// with `git credential fill`. Terminal prompts, askpass programs and interactive helpers are disabled,
+// so git fails instead of prompting if no helper has the credentials.
+// It returns nil if the credentials are not found.
+func credentialHelperAuth(ctx context.Context, endpoint *transport.Endpoint) *http.BasicAuth {
+	logger := slog.Default().With(slog.String("host", endpoint.Host))
+	host := endpoint.Host
+	if endpoint.Port != 0 {
+		host += ":" + strconv.Itoa(endpoint.Port)
+	}
+	input := fmt.Sprintf(
+		"protocol=%s\nhost=%s\npath=%s\n",
+		endpoint.Protocol, host, strings.TrimPrefix(endpoint.Path, "/"),
+	)
+	if endpoint.User != "" {
+		input += "username=" + endpoint.User + "\n"
+	}
+	input += "\n"
+	cmd := exec.CommandContext(
+		ctx, "git",
+		"-c", "credential.interactive=false",
+		"-c", "core.askPass=",
+		"credential", "fill",
+	)
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(
+		os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ASKPASS=",
+		"SSH_ASKPASS=",
+		"GCM_INTERACTIVE=never",
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		logger.Debug("Git credential helpers have no credentials", slog.String("error", err.Error()))
+		return nil
+	}
+	var auth http.BasicAuth
+	for _, line := range strings.Split(string(output), "\n") {
+		key, value, _ := strings.Cut(line, "=")
+		switch key {
+		case "username":
+			auth.Username = value
+		case "password":
+			auth.Password = value
+		}
+	}
+	if auth.Password == "" {
+		return nil
+	}
+	logger.Debug("Git credential helpers have credentials")
+	return &auth
+}
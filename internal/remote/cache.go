@@ -0,0 +1,156 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"golang.org/x/sys/unix"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// OpenCache opens the clone of the remote repository kept in the cache directory and fetches it,
+// or clones the repository into the directory if it has no clone yet.
+// The clone is bare, it keeps the objects and the references only, the same as the memory clone.
+// The options are used to clone and fetch the repository.
+// If the fetch fails, for example, when the remote is offline, the cached clone is used as is.
+// The cache directory is locked while it is cloned or fetched, so the mounts sharing it wait for each other.
+// The objects read from the clone are cached in the object cache.
+// The returned storage should be closed after the repository is no longer used.
+func OpenCache(
+	ctx context.Context,
+	dir, url string,
+	options Options,
+	objectCache cache.Object,
+	progress io.Writer,
+) (*git.Repository, *filesystem.Storage, error) {
+	unlock, err := lockCache(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+	empty, err := isEmptyDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if empty {
+		if err := cloneCache(ctx, dir, url, options, progress); err != nil {
+			return nil, nil, fmt.Errorf("clone into cache: %w", err)
+		}
+	}
+
+	storage := filesystem.NewStorageWithOptions(
+		osfs.New(dir),
+		objectCache,
+		filesystem.Options{KeepDescriptors: true},
+	)
+	if err := objectformat.Check(storage); err != nil {
+		_ = storage.Close()
+		return nil, nil, fmt.Errorf("open cache directory %s: %w", dir, err)
+	}
+	repository, err := git.Open(storage, nil)
+	if err != nil {
+		_ = storage.Close()
+		return nil, nil, fmt.Errorf("open cache directory %s: %w", dir, err)
+	}
+	if empty {
+		return repository, storage, nil
+	}
+	if err := checkRemoteURL(repository, url); err != nil {
+		_ = storage.Close()
+		return nil, nil, fmt.Errorf("open cache directory %s: %w", dir, err)
+	}
+	if _, err := Fetch(ctx, repository, git.DefaultRemoteName, options); err != nil {
+		slog.Default().Warn("Error fetch cached repository, using cached clone", slog.String("error", err.Error()))
+	}
+	return repository, storage, nil
+}
+
+// lockFileSuffix is the suffix of the cache directory lock file name.
+const lockFileSuffix = ".lock"
+
+// lockCache takes the exclusive lock of the cache directory, waiting until the other process holding it releases it.
+// The lock is the file next to the directory, since the directory itself is replaced by the clone.
+// It returns the function releasing the lock.
+func lockCache(dir string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+		return nil, fmt.Errorf("create cache directory: %w", err)
+	}
+	file, err := os.OpenFile(dir+lockFileSuffix, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open cache lock: %w", err)
+	}
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("lock cache directory %s: %w", dir, err)
+	}
+	return func() {
+		_ = unix.Flock(int(file.Fd()), unix.LOCK_UN)
+		_ = file.Close()
+	}, nil
+}
+
+// cloneCache clones the repository into the temporary directory next to the cache directory
+// and renames it into place, so the cache directory never has a partial clone.
+// The cache directory is expected to be missing or empty.
+func cloneCache(ctx context.Context, dir, url string, options Options, progress io.Writer) error {
+	tempDir, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".clone-")
+	if err != nil {
+		return fmt.Errorf("create temporary directory: %w", err)
+	}
+	if err := os.Chmod(tempDir, 0755); err != nil {
+		_ = os.RemoveAll(tempDir)
+		return fmt.Errorf("create temporary directory: %w", err)
+	}
+	storage := filesystem.NewStorage(osfs.New(tempDir), cache.NewObjectLRUDefault())
+	_, err = Clone(ctx, storage, url, options, progress)
+	if closeErr := storage.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.RemoveAll(tempDir)
+		return err
+	}
+	if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		_ = os.RemoveAll(tempDir)
+		return fmt.Errorf("replace cache directory: %w", err)
+	}
+	if err := os.Rename(tempDir, dir); err != nil {
+		_ = os.RemoveAll(tempDir)
+		return fmt.Errorf("replace cache directory: %w", err)
+	}
+	return nil
+}
+
+// isEmptyDir reports whether the directory is missing or has no entries.
+func isEmptyDir(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("read cache directory: %w", err)
+	}
+	return len(entries) == 0, nil
+}
+
+// checkRemoteURL returns an error if the repository is cloned from another URL,
+// so a cache directory is never fetched from a remote it doesn't belong to.
+func checkRemoteURL(repository *git.Repository, url string) error {
+	origin, err := repository.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return fmt.Errorf("remote %s: %w", git.DefaultRemoteName, err)
+	}
+	urls := origin.Config().URLs
+	if len(urls) == 0 || urls[0] != url {
+		return fmt.Errorf("repository is cloned from %v, not %s", urls, url)
+	}
+	return nil
+}
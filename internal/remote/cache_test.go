@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"context"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestOpenCache(t *testing.T) {
+	skipUnlessSHA1(t)
+	originPath := t.TempDir()
+	origin, err := git.PlainInit(originPath, false)
+	require.NoError(t, err)
+	first := commitFile(t, origin, originPath, "first\n")
+	url := "file://" + originPath
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	repository, storage, err := OpenCache(context.Background(), cacheDir, url, Options{}, cache.NewObjectLRUDefault(), nil)
+	require.NoError(t, err)
+	requireBranch(t, repository, first)
+	require.NoError(t, storage.Close())
+	_, err = os.Stat(filepath.Join(cacheDir, "objects"))
+	require.NoError(t, err, "the clone must be bare")
+
+	second := commitFile(t, origin, originPath, "second\n")
+	repository, storage, err = OpenCache(context.Background(), cacheDir, url, Options{}, cache.NewObjectLRUDefault(), nil)
+	require.NoError(t, err)
+	requireBranch(t, repository, second)
+	require.NoError(t, storage.Close())
+
+	// The cached clone is used when the remote is not available.
+	require.NoError(t, os.RemoveAll(originPath))
+	repository, storage, err = OpenCache(context.Background(), cacheDir, url, Options{}, cache.NewObjectLRUDefault(), nil)
+	require.NoError(t, err)
+	requireBranch(t, repository, second)
+	require.NoError(t, storage.Close())
+
+	_, _, err = OpenCache(context.Background(), cacheDir, "file:///other", Options{}, cache.NewObjectLRUDefault(), nil)
+	require.ErrorContains(t, err, "not file:///other")
+}
+
+func TestOpenCacheCloneError(t *testing.T) {
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	missingURL := "file://" + filepath.Join(t.TempDir(), "missing")
+	_, _, err := OpenCache(context.Background(), cacheDir, missingURL, Options{}, cache.NewObjectLRUDefault(), nil)
+	require.Error(t, err)
+	_, err = os.Stat(cacheDir)
+	require.True(t, os.IsNotExist(err), "the failed clone must not be left in the cache")
+	entries, err := os.ReadDir(filepath.Dir(cacheDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "only the lock file must be left next to the cache")
+	require.Equal(t, "cache"+lockFileSuffix, entries[0].Name())
+
+	cacheDir = t.TempDir()
+	_, _, err = OpenCache(context.Background(), cacheDir, missingURL, Options{}, cache.NewObjectLRUDefault(), nil)
+	require.Error(t, err)
+	entries, err = os.ReadDir(cacheDir)
+	require.NoError(t, err, "the existing cache directory must be kept")
+	require.Empty(t, entries)
+}
+
+func TestOpenCacheConcurrently(t *testing.T) {
+	skipUnlessSHA1(t)
+	originPath := t.TempDir()
+	origin, err := git.PlainInit(originPath, false)
+	require.NoError(t, err)
+	first := commitFile(t, origin, originPath, "first\n")
+	url := "file://" + originPath
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+
+	// The mounts sharing the cache directory wait for each other, the first one clones it.
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repository, storage, err := OpenCache(context.Background(), cacheDir, url, Options{}, cache.NewObjectLRUDefault(), nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer storage.Close()
+			head, err := repository.Reference(plumbing.Master, true)
+			if err == nil && head.Hash() != first {
+				err = fmt.Errorf("master is %s, not %s", head.Hash(), first)
+			}
+			errs[i] = err
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(cacheDir))
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "no temporary clone must be left next to the cache")
+	require.Equal(t, []string{"cache", "cache" + lockFileSuffix}, []string{entries[0].Name(), entries[1].Name()})
+}
+
+func TestOpenCacheObjectFormat(t *testing.T) {
+	other := format.SHA256
+	if objectformat.Current() == format.SHA256 {
+		other = format.SHA1
+	}
+	cacheDir := t.TempDir()
+	output, err := exec.Command("git", "init", "-q", "--bare", "--object-format="+string(other), cacheDir).CombinedOutput()
+	require.NoError(t, err, string(output))
+
+	_, _, err = OpenCache(context.Background(), cacheDir, "file:///other", Options{}, cache.NewObjectLRUDefault(), nil)
+	require.ErrorIs(t, err, objectformat.ErrUnsupported)
+}
@@ -0,0 +1,174 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"io"
+	"log/slog"
+)
+
+// Options restrict what is cloned and fetched from the remote repository.
+type Options struct {
+	// Depth limits the history to the number of commits from the fetched tips,
+	// zero fetches the full history.
+	Depth int
+	// Branch is the branch to clone, the remote HEAD by default.
+	Branch string
+	// SingleBranch clones the Branch only.
+	SingleBranch bool
+	// Tags selects the tags to fetch. All tags are fetched by default,
+	// except if SingleBranch or RefSpecs are set, then the default is git.TagFollowing,
+	// which fetches the annotated tags pointing into the history fetched by wildcard refspecs,
+	// so the single branch clone has no tags unless they are requested.
+	Tags git.TagMode
+	// RefSpecs replace the refspecs of the remote, for example,
+	// "+refs/heads/release/*:refs/heads/release/*" fetches the release branches only.
+	// The Branch and SingleBranch are ignored if RefSpecs are set.
+	RefSpecs []config.RefSpec
+	// Auth authenticates to the remote repository, see NewAuth.
+	Auth transport.AuthMethod
+	// Reauth returns other credentials after the remote rejects the Auth, see NewReauth.
+	// The request is retried once with them, and they replace the Auth of the next fetches of the Fetcher.
+	// It returns nil if there are no other credentials.
+	Reauth func(ctx context.Context) transport.AuthMethod
+}
+
+func (options Options) tags() git.TagMode {
+	switch {
+	case options.Tags != git.InvalidTagMode:
+		return options.Tags
+	case options.SingleBranch || len(options.RefSpecs) > 0:
+		return git.TagFollowing
+	default:
+		return git.AllTags
+	}
+}
+
+// reauthenticate replaces the Auth with the credentials of the Reauth if the error is the remote rejecting the Auth.
+// It reports whether the Auth is replaced, so the request should be retried.
+func (options *Options) reauthenticate(ctx context.Context, err error) bool {
+	if options.Reauth == nil ||
+		!(errors.Is(err, transport.ErrAuthenticationRequired) || errors.Is(err, transport.ErrAuthorizationFailed)) {
+		return false
+	}
+	auth := options.Reauth(ctx)
+	if auth == nil {
+		return false
+	}
+	slog.Default().Info("Remote rejected the credentials, retrying with other credentials")
+	options.Auth = auth
+	return true
+}
+
+// authenticate replaces the Auth with the credentials of the Reauth if the remote rejects it.
+// The remote is listed before the clone, since the rejected clone leaves the storage initialized.
+func (options *Options) authenticate(ctx context.Context, url string) {
+	if options.Reauth == nil {
+		return
+	}
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{url}})
+	_, err := remote.ListContext(ctx, &git.ListOptions{Auth: options.Auth})
+	options.reauthenticate(ctx, err)
+}
+
+// checkObjectFormat returns an error if gitfs is built for SHA-256,
+// since go-git speaks the protocol of SHA-1 repositories only.
+func checkObjectFormat() error {
+	if objectformat.Current() == format.SHA1 {
+		return nil
+	}
+	return fmt.Errorf(
+		"%w: this gitfs is built for %s, repositories mounted by URL are supported only by %s",
+		objectformat.ErrUnsupported, objectformat.Current(), objectformat.Build(format.SHA1),
+	)
+}
+
+// Clone clones the remote repository into the storage without a worktree.
+// The credentials of the Reauth are asked for before the clone if the remote rejects the Auth.
+// It returns an error if the cloned repository has another object format than gitfs is built for.
+func Clone(ctx context.Context, storage storage.Storer, url string, options Options, progress io.Writer) (*git.Repository, error) {
+	if err := checkObjectFormat(); err != nil {
+		return nil, err
+	}
+	options.authenticate(ctx, url)
+	repository, err := clone(ctx, storage, url, options, progress)
+	if err != nil {
+		return nil, err
+	}
+	if err := objectformat.Check(storage); err != nil {
+		return nil, fmt.Errorf("clone %s: %w", url, err)
+	}
+	return repository, nil
+}
+
+func clone(ctx context.Context, storage storage.Storer, url string, options Options, progress io.Writer) (*git.Repository, error) {
+	if len(options.RefSpecs) > 0 {
+		return cloneRefSpecs(ctx, storage, url, options, progress)
+	}
+	cloneOptions := &git.CloneOptions{
+		URL:          url,
+		NoCheckout:   true,
+		Progress:     progress,
+		Depth:        options.Depth,
+		SingleBranch: options.SingleBranch,
+		Tags:         options.tags(),
+		Auth:         options.Auth,
+	}
+	if options.Branch != "" {
+		cloneOptions.ReferenceName = plumbing.NewBranchReferenceName(options.Branch)
+	}
+	repository, err := git.CloneContext(ctx, storage, nil, cloneOptions)
+	if err != nil {
+		return nil, fmt.Errorf("clone %s: %w", url, err)
+	}
+	return repository, nil
+}
+
+// cloneRefSpecs creates the repository with the remote fetching the refspecs, and fetches it.
+// No local branches are created, the refspecs are expected to fetch into them directly.
+func cloneRefSpecs(
+	ctx context.Context,
+	storage storage.Storer,
+	url string,
+	options Options,
+	progress io.Writer,
+) (*git.Repository, error) {
+	for _, refSpec := range options.RefSpecs {
+		if err := refSpec.Validate(); err != nil {
+			return nil, fmt.Errorf("refspec %s: %w", refSpec, err)
+		}
+	}
+	repository, err := git.Init(storage, nil)
+	if err != nil {
+		return nil, fmt.Errorf("init: %w", err)
+	}
+	_, err = repository.CreateRemote(&config.RemoteConfig{
+		Name:  git.DefaultRemoteName,
+		URLs:  []string{url},
+		Fetch: options.RefSpecs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create remote: %w", err)
+	}
+	err = repository.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: git.DefaultRemoteName,
+		Depth:      options.Depth,
+		Tags:       options.tags(),
+		Auth:       options.Auth,
+		Progress:   progress,
+		Force:      true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	return repository, nil
+}
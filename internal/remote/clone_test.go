@@ -0,0 +1,119 @@
+package remote
+
+import (
+	"context"
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	skipUnlessSHA1(t)
+	originPath := t.TempDir()
+	origin, err := git.PlainInit(originPath, false)
+	require.NoError(t, err)
+	first := commitFile(t, origin, originPath, "first\n")
+	_, err = origin.CreateTag("v1.0.0", first, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "gitfs", Email: "gitfs@example.com", When: time.Now()},
+		Message: "v1.0.0",
+	})
+	require.NoError(t, err)
+	second := commitFile(t, origin, originPath, "second\n")
+	require.NoError(t, origin.Storer.SetReference(plumbing.NewHashReference("refs/heads/release/1.0", first)))
+	url := "file://" + originPath
+
+	tests := []struct {
+		name       string
+		options    Options
+		head       plumbing.Hash
+		shallow    []plumbing.Hash
+		references []string
+	}{
+		{
+			name:    "full",
+			options: Options{},
+			head:    second,
+			references: []string{
+				"HEAD",
+				"refs/heads/master",
+				"refs/remotes/origin/master",
+				"refs/remotes/origin/release/1.0",
+				"refs/tags/v1.0.0",
+			},
+		},
+		{
+			name:       "depth",
+			options:    Options{Depth: 1, Tags: git.NoTags},
+			head:       second,
+			shallow:    []plumbing.Hash{second, first},
+			references: []string{"HEAD", "refs/heads/master", "refs/remotes/origin/master", "refs/remotes/origin/release/1.0"},
+		},
+		{
+			name:       "single branch",
+			options:    Options{Branch: "release/1.0", SingleBranch: true},
+			head:       first,
+			references: []string{"HEAD", "refs/heads/release/1.0", "refs/remotes/origin/release/1.0"},
+		},
+		{
+			name:       "single branch with tags",
+			options:    Options{Branch: "release/1.0", SingleBranch: true, Tags: git.AllTags},
+			head:       first,
+			references: []string{"HEAD", "refs/heads/release/1.0", "refs/remotes/origin/release/1.0", "refs/tags/v1.0.0"},
+		},
+		{
+			name: "refspecs",
+			options: Options{
+				RefSpecs: []config.RefSpec{"+refs/heads/release/*:refs/heads/release/*"},
+			},
+			references: []string{"HEAD", "refs/heads/release/1.0", "refs/tags/v1.0.0"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repository, err := Clone(context.Background(), NewMemoryStorage(), url, test.options, nil)
+			require.NoError(t, err)
+			if !test.head.IsZero() {
+				requireBranch(t, repository, test.head)
+			}
+			shallow, err := repository.Storer.Shallow()
+			require.NoError(t, err)
+			require.ElementsMatch(t, test.shallow, shallow)
+			require.Equal(t, test.references, referenceNames(t, repository))
+
+			_, err = Fetch(context.Background(), repository, git.DefaultRemoteName, test.options)
+			require.NoError(t, err)
+			require.Equal(t, test.references, referenceNames(t, repository), "the fetch must keep the options")
+		})
+	}
+
+	_, err = Clone(context.Background(), NewMemoryStorage(), url, Options{RefSpecs: []config.RefSpec{"refs/heads/*"}}, nil)
+	require.ErrorContains(t, err, "refspec refs/heads/*")
+}
+
+func TestCloneObjectFormat(t *testing.T) {
+	if objectformat.Current() == format.SHA1 {
+		t.Skip("repositories mounted by URL are supported by gitfs built for SHA-1")
+	}
+	_, err := Clone(context.Background(), NewMemoryStorage(), "file:///repo.git", Options{}, nil)
+	require.ErrorIs(t, err, objectformat.ErrUnsupported)
+}
+
+func referenceNames(t *testing.T, repository *git.Repository) []string {
+	t.Helper()
+	references, err := repository.Storer.IterReferences()
+	require.NoError(t, err)
+	var names []string
+	require.NoError(t, references.ForEach(func(reference *plumbing.Reference) error {
+		names = append(names, reference.Name().String())
+		return nil
+	}))
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,189 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"log/slog"
+	"maps"
+	"time"
+)
+
+// Fetch fetches the remote into the repository and moves the local branches
+// to the fetched branches they track, the same as a fast-forward pull without a checkout does,
+// except that the branches are moved even if the remote history has been rewritten.
+// The fetched branches are the ones the remote is configured to fetch when the repository is cloned,
+// the options limit the depth and the tags and authenticate the same way they do for the clone.
+// The remote-tracking branches deleted on the remote are pruned, and so are the local branches fetched
+// directly by the refspecs. The local branches tracking the pruned ones are kept, the same as git does.
+// If the remote rejects the Auth, the repository is fetched again with the credentials of the Reauth.
+// It reports whether any reference has changed, also if the fetch fails after some references are updated.
+func Fetch(ctx context.Context, repository *git.Repository, remoteName string, options Options) (bool, error) {
+	return fetchReauthenticated(ctx, repository, remoteName, &options)
+}
+
+// fetchReauthenticated fetches the repository, and fetches it again if the remote rejects the Auth
+// and the Reauth replaces it in the options, so the next fetches use the replaced credentials.
+func fetchReauthenticated(ctx context.Context, repository *git.Repository, remoteName string, options *Options) (bool, error) {
+	changed, err := fetch(ctx, repository, remoteName, *options)
+	if options.reauthenticate(ctx, err) {
+		return fetch(ctx, repository, remoteName, *options)
+	}
+	return changed, err
+}
+
+func fetch(ctx context.Context, repository *git.Repository, remoteName string, options Options) (bool, error) {
+	if err := checkObjectFormat(); err != nil {
+		return false, err
+	}
+	// The references are compared, since go-git reports the pruning fetch as changed even if nothing is pruned.
+	before, err := referencesSnapshot(repository)
+	if err != nil {
+		return false, err
+	}
+	err = repository.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: remoteName,
+		Depth:      options.Depth,
+		Tags:       options.tags(),
+		Auth:       options.Auth,
+		Force:      true,
+		Prune:      true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		err = fmt.Errorf("fetch %s: %w", remoteName, err)
+	} else {
+		err = updateBranches(repository, remoteName)
+	}
+	after, snapshotErr := referencesSnapshot(repository)
+	if snapshotErr != nil {
+		return true, errors.Join(err, snapshotErr)
+	}
+	return !maps.Equal(before, after), err
+}
+
+// referencesSnapshot returns the targets of the repository references by their names.
+func referencesSnapshot(repository *git.Repository) (map[plumbing.ReferenceName]string, error) {
+	refs, err := repository.Storer.IterReferences()
+	if err != nil {
+		return nil, fmt.Errorf("repository: references: %w", err)
+	}
+	snapshot := make(map[plumbing.ReferenceName]string)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		snapshot[ref.Name()] = ref.Strings()[1]
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repository: references: %w", err)
+	}
+	return snapshot, nil
+}
+
+// updateBranches moves the local branches tracking the branches of the remote
+// to the remote-tracking branches.
+func updateBranches(repository *git.Repository, remoteName string) error {
+	cfg, err := repository.Config()
+	if err != nil {
+		return fmt.Errorf("repository: config: %w", err)
+	}
+	for name, branch := range cfg.Branches {
+		if branch.Remote != remoteName || !branch.Merge.IsBranch() {
+			continue
+		}
+		tracking, err := repository.Reference(plumbing.NewRemoteReferenceName(remoteName, branch.Merge.Short()), true)
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("repository: reference: %w", err)
+		}
+		local, err := repository.Reference(plumbing.NewBranchReferenceName(name), false)
+		if err == nil && local.Hash() == tracking.Hash() {
+			continue
+		}
+		if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+			return fmt.Errorf("repository: reference: %w", err)
+		}
+		err = repository.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(name), tracking.Hash()))
+		if err != nil {
+			return fmt.Errorf("repository: set reference: %w", err)
+		}
+	}
+	return nil
+}
+
+// Fetcher fetches the repository from the remote periodically and on demand.
+type Fetcher struct {
+	repository *git.Repository
+	remoteName string
+	options    Options
+	interval   time.Duration
+	onChange   func()
+	trigger    chan struct{}
+}
+
+// NewFetcher creates a new Fetcher fetching the repository from the remote every interval,
+// the zero interval fetches on demand only. The onChange is called after the references change.
+func NewFetcher(
+	repository *git.Repository,
+	remoteName string,
+	options Options,
+	interval time.Duration,
+	onChange func(),
+) *Fetcher {
+	return &Fetcher{
+		repository: repository,
+		remoteName: remoteName,
+		options:    options,
+		interval:   interval,
+		onChange:   onChange,
+		trigger:    make(chan struct{}, 1),
+	}
+}
+
+// Trigger requests the fetch without waiting for the interval.
+// Requests made while the repository is being fetched are coalesced into one more fetch.
+func (fetcher *Fetcher) Trigger() {
+	select {
+	case fetcher.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run fetches the repository until the context is done.
+// Fetch errors are logged, and the repository is fetched again on the next tick or request.
+func (fetcher *Fetcher) Run(ctx context.Context) {
+	var tick <-chan time.Time
+	if fetcher.interval > 0 {
+		ticker := time.NewTicker(fetcher.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick:
+		case <-fetcher.trigger:
+		}
+		fetcher.fetch(ctx)
+	}
+}
+
+func (fetcher *Fetcher) fetch(ctx context.Context) {
+	logger := slog.Default().With(slog.String("remote", fetcher.remoteName))
+	changed, err := fetchReauthenticated(ctx, fetcher.repository, fetcher.remoteName, &fetcher.options)
+	switch {
+	case err != nil:
+		// The references might be updated partially, the changes are reported anyway.
+		logger.Error("Error fetch repository", slog.String("error", err.Error()))
+	case !changed:
+		logger.Info("Repository is up to date")
+	default:
+		logger.Info("Repository fetched")
+	}
+	if changed {
+		fetcher.onChange()
+	}
+}
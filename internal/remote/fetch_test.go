@@ -0,0 +1,122 @@
+package remote
+
+import (
+	"context"
+	"github.com/dsxack/gitfs/internal/objectformat"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+	skipUnlessSHA1(t)
+	originPath := t.TempDir()
+	origin, err := git.PlainInit(originPath, false)
+	require.NoError(t, err)
+	first := commitFile(t, origin, originPath, "first\n")
+	feature := plumbing.NewBranchReferenceName("feature")
+	require.NoError(t, origin.Storer.SetReference(plumbing.NewHashReference(feature, first)))
+
+	repository, err := git.Clone(NewMemoryStorage(), nil, &git.CloneOptions{
+		URL:        "file://" + originPath,
+		NoCheckout: true,
+	})
+	require.NoError(t, err)
+	requireBranch(t, repository, first)
+
+	changed, err := Fetch(context.Background(), repository, git.DefaultRemoteName, Options{})
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	second := commitFile(t, origin, originPath, "second\n")
+	_, err = origin.CreateTag("v1.0.0", second, nil)
+	require.NoError(t, err)
+
+	changed, err = Fetch(context.Background(), repository, git.DefaultRemoteName, Options{})
+	require.NoError(t, err)
+	require.True(t, changed)
+	requireBranch(t, repository, second)
+	tag, err := repository.Reference(plumbing.NewTagReferenceName("v1.0.0"), true)
+	require.NoError(t, err)
+	require.Equal(t, second, tag.Hash())
+
+	// The branch deleted on the remote is pruned.
+	require.NoError(t, origin.Storer.RemoveReference(feature))
+	changed, err = Fetch(context.Background(), repository, git.DefaultRemoteName, Options{})
+	require.NoError(t, err)
+	require.True(t, changed)
+	_, err = repository.Reference(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, "feature"), false)
+	require.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+}
+
+func TestFetcher(t *testing.T) {
+	skipUnlessSHA1(t)
+	originPath := t.TempDir()
+	origin, err := git.PlainInit(originPath, false)
+	require.NoError(t, err)
+	commitFile(t, origin, originPath, "first\n")
+	repository, err := git.Clone(NewMemoryStorage(), nil, &git.CloneOptions{
+		URL:        "file://" + originPath,
+		NoCheckout: true,
+	})
+	require.NoError(t, err)
+
+	changes := make(chan struct{}, 1)
+	fetcher := NewFetcher(repository, git.DefaultRemoteName, Options{}, 0, func() { changes <- struct{}{} })
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go fetcher.Run(ctx)
+
+	// The mounted filesystem reads the repository while it is fetched.
+	go func() {
+		for ctx.Err() == nil {
+			_, _ = repository.Head()
+			_, _ = repository.CommitObjects()
+		}
+	}()
+
+	second := commitFile(t, origin, originPath, "second\n")
+	fetcher.Trigger()
+	select {
+	case <-changes:
+	case <-time.After(10 * time.Second):
+		t.Fatal("fetched changes are not reported")
+	}
+	requireBranch(t, repository, second)
+}
+
+// skipUnlessSHA1 skips the test cloning or fetching the repository if gitfs is built for SHA-256,
+// see checkObjectFormat.
+func skipUnlessSHA1(t *testing.T) {
+	t.Helper()
+	if objectformat.Current() != format.SHA1 {
+		t.Skip("repositories mounted by URL are supported only by gitfs built for SHA-1")
+	}
+}
+
+func commitFile(t *testing.T, repository *git.Repository, path, content string) plumbing.Hash {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(path, "file"), []byte(content), 0644))
+	worktree, err := repository.Worktree()
+	require.NoError(t, err)
+	_, err = worktree.Add("file")
+	require.NoError(t, err)
+	hash, err := worktree.Commit(content, &git.CommitOptions{
+		Author: &object.Signature{Name: "gitfs", Email: "gitfs@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return hash
+}
+
+func requireBranch(t *testing.T, repository *git.Repository, hash plumbing.Hash) {
+	t.Helper()
+	head, err := repository.Head()
+	require.NoError(t, err)
+	require.Equal(t, hash, head.Hash())
+}
@@ -0,0 +1,142 @@
+// Package remote clones and fetches the repositories mounted by URL.
+package remote
+
+import (
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"sync"
+)
+
+// lockedStorage serializes the access to the storage not safe for concurrent use,
+// so the repository can be fetched into while the mounted filesystem reads it.
+type lockedStorage struct {
+	storage.Storer
+	mu sync.RWMutex
+}
+
+// NewMemoryStorage creates a memory storage safe for concurrent use.
+// The memory storage keeps objects and references in maps without locking,
+// which is not enough to fetch into the mounted repository.
+func NewMemoryStorage() storage.Storer {
+	return &lockedStorage{Storer: memory.NewStorage()}
+}
+
+func (s *lockedStorage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.SetEncodedObject(obj)
+}
+
+func (s *lockedStorage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Storer.EncodedObject(t, h)
+}
+
+func (s *lockedStorage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Storer.IterEncodedObjects(t)
+}
+
+func (s *lockedStorage) HasEncodedObject(h plumbing.Hash) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Storer.HasEncodedObject(h)
+}
+
+func (s *lockedStorage) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Storer.EncodedObjectSize(h)
+}
+
+func (s *lockedStorage) AddAlternate(remote string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.AddAlternate(remote)
+}
+
+func (s *lockedStorage) SetReference(ref *plumbing.Reference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.SetReference(ref)
+}
+
+func (s *lockedStorage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.CheckAndSetReference(ref, old)
+}
+
+func (s *lockedStorage) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Storer.Reference(n)
+}
+
+func (s *lockedStorage) IterReferences() (storer.ReferenceIter, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Storer.IterReferences()
+}
+
+func (s *lockedStorage) RemoveReference(n plumbing.ReferenceName) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.RemoveReference(n)
+}
+
+func (s *lockedStorage) CountLooseRefs() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Storer.CountLooseRefs()
+}
+
+func (s *lockedStorage) PackRefs() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.PackRefs()
+}
+
+func (s *lockedStorage) SetShallow(commits []plumbing.Hash) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.SetShallow(commits)
+}
+
+func (s *lockedStorage) Shallow() ([]plumbing.Hash, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Storer.Shallow()
+}
+
+func (s *lockedStorage) SetIndex(idx *index.Index) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.SetIndex(idx)
+}
+
+func (s *lockedStorage) Index() (*index.Index, error) {
+	// The memory storage creates the missing value on read.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.Index()
+}
+
+func (s *lockedStorage) SetConfig(cfg *config.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.SetConfig(cfg)
+}
+
+func (s *lockedStorage) Config() (*config.Config, error) {
+	// The memory storage creates the missing value on read.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Storer.Config()
+}
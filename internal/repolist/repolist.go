@@ -0,0 +1,147 @@
+// Package repolist lists the repositories mounted together under one mount point.
+package repolist
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/bundle"
+	"github.com/dsxack/gitfs/internal/gitdir"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const dotGit = ".git"
+
+// Repository is the repository mounted as the subdirectory with the name.
+type Repository struct {
+	// Name is the name of the subdirectory.
+	Name string
+	// Path is the path or the URL of the repository, as given to mount a single repository.
+	Path string
+}
+
+// Name returns the name of the subdirectory of the repository path or URL: its last path element
+// without .git and .bundle extensions, for example, "project" for "/work/project/.git",
+// "https://example.com/project.git" and "git@example.com:project.git".
+func Name(repository string) string {
+	name := strings.TrimRight(repository, "/")
+	if strings.HasSuffix(name, "/"+dotGit) {
+		name = strings.TrimSuffix(name, "/"+dotGit)
+	}
+	name = name[strings.LastIndexAny(name, "/:")+1:]
+	name = strings.TrimSuffix(name, ".bundle")
+	return strings.TrimSuffix(name, dotGit)
+}
+
+// FromPaths returns the repositories of the paths or URLs named after them.
+func FromPaths(paths []string) []Repository {
+	repositories := make([]Repository, 0, len(paths))
+	for _, path := range paths {
+		repositories = append(repositories, Repository{Name: Name(path), Path: path})
+	}
+	return repositories
+}
+
+// ReadDir returns the repositories in the directory: the worktrees, the bare repositories
+// and the bundle files, named after their entries. Other entries are skipped.
+func ReadDir(dir string) ([]Repository, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read repositories directory: %w", err)
+	}
+	var repositories []Repository
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !isRepository(path) {
+			continue
+		}
+		repositories = append(repositories, Repository{Name: Name(path), Path: path})
+	}
+	return repositories, nil
+}
+
+// isRepository reports whether the path is a worktree, a bare repository or a bundle file.
+// The parent directories are not searched, unlike git does.
+func isRepository(path string) bool {
+	if _, err := gitdir.Resolve(filepath.Join(path, dotGit)); err == nil {
+		return true
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if !info.IsDir() {
+		return bundle.IsBundle(path)
+	}
+	_, err = gitdir.Resolve(path)
+	return err == nil
+}
+
+// ReadFile returns the repositories listed in the file, one per line, either as
+// "<repository>" named after it, or as "<name> <repository>".
+// Empty lines and lines starting with # are skipped.
+// Relative repository paths are relative to the directory of the file.
+func ReadFile(path string) ([]Repository, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open repositories file: %w", err)
+	}
+	defer file.Close()
+	var repositories []Repository
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var repository Repository
+		switch fields := strings.Fields(line); len(fields) {
+		case 1:
+			repository = Repository{Name: Name(fields[0]), Path: fields[0]}
+		case 2:
+			repository = Repository{Name: fields[0], Path: fields[1]}
+		default:
+			return nil, fmt.Errorf("%s:%d: expected [<name>] <repository>, got %q", path, lineNumber, line)
+		}
+		if relative := filepath.Join(filepath.Dir(path), repository.Path); !filepath.IsAbs(repository.Path) {
+			// The URL is kept as is, since there is no such file.
+			if _, err := os.Stat(relative); err == nil {
+				repository.Path = relative
+			}
+		}
+		repositories = append(repositories, repository)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read repositories file: %w", err)
+	}
+	return repositories, nil
+}
+
+// Merge joins the lists of repositories, sorted by name.
+// It returns an error if two repositories have the same name or the name is not a valid file name.
+func Merge(lists ...[]Repository) ([]Repository, error) {
+	var merged []Repository
+	paths := make(map[string]string)
+	for _, list := range lists {
+		for _, repository := range list {
+			if repository.Name == "" || repository.Name == "." || repository.Name == ".." ||
+				strings.ContainsAny(repository.Name, "/\x00") {
+				return nil, fmt.Errorf("invalid name %q of repository %s", repository.Name, repository.Path)
+			}
+			if path, ok := paths[repository.Name]; ok {
+				return nil, fmt.Errorf(
+					"repositories %s and %s have the same name %s, name one of them in the repositories file",
+					path, repository.Path, repository.Name,
+				)
+			}
+			paths[repository.Name] = repository.Path
+			merged = append(merged, repository)
+		}
+	}
+	slices.SortFunc(merged, func(a, b Repository) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return merged, nil
+}
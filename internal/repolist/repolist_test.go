@@ -0,0 +1,94 @@
+package repolist
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestName(t *testing.T) {
+	tests := map[string]string{
+		"/work/project":                         "project",
+		"/work/project/":                        "project",
+		"/work/project/.git":                    "project",
+		"/work/project.git":                     "project",
+		"release.bundle":                        "release",
+		"https://example.com/org/project.git":   "project",
+		"ssh://git@example.com/org/project.git": "project",
+		"git@example.com:project.git":           "project",
+	}
+	for repository, expected := range tests {
+		require.Equal(t, expected, Name(repository), repository)
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	root := t.TempDir()
+	runGit(t, root, "init", "-q", "worktree")
+	runGit(t, root, "init", "-q", "--bare", "bare.git")
+	require.NoError(t, os.Mkdir(filepath.Join(root, "plain"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("notes\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "release.bundle"), []byte("# v2 git bundle\n"), 0644))
+
+	repositories, err := ReadDir(root)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []Repository{
+		{Name: "worktree", Path: filepath.Join(root, "worktree")},
+		{Name: "bare", Path: filepath.Join(root, "bare.git")},
+		{Name: "release", Path: filepath.Join(root, "release.bundle")},
+	}, repositories)
+}
+
+func TestReadFile(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, "local"), 0755))
+	path := filepath.Join(root, "repositories")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"# services\n"+
+			"https://example.com/org/api.git\n"+
+			"\n"+
+			"web  git@example.com:org/frontend.git\n"+
+			"local\n",
+	), 0644))
+
+	repositories, err := ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, []Repository{
+		{Name: "api", Path: "https://example.com/org/api.git"},
+		{Name: "web", Path: "git@example.com:org/frontend.git"},
+		{Name: "local", Path: filepath.Join(root, "local")},
+	}, repositories)
+
+	require.NoError(t, os.WriteFile(path, []byte("name repository extra\n"), 0644))
+	_, err = ReadFile(path)
+	require.ErrorContains(t, err, path+":1: expected [<name>] <repository>")
+}
+
+func TestMerge(t *testing.T) {
+	merged, err := Merge(
+		FromPaths([]string{"/work/web", "/work/api"}),
+		[]Repository{{Name: "docs", Path: "https://example.com/org/docs.git"}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []Repository{
+		{Name: "api", Path: "/work/api"},
+		{Name: "docs", Path: "https://example.com/org/docs.git"},
+		{Name: "web", Path: "/work/web"},
+	}, merged)
+
+	_, err = Merge(FromPaths([]string{"/work/api", "/other/api.git"}))
+	require.ErrorContains(t, err, "repositories /work/api and /other/api.git have the same name api")
+	_, err = Merge([]Repository{{Name: "..", Path: "/work/api"}})
+	require.ErrorContains(t, err, `invalid name ".." of repository /work/api`)
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "GIT_CONFIG_GLOBAL=/dev/null")
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
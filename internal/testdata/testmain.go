@@ -18,11 +18,28 @@ var testRepositoryZip []byte
 type rootNodeFactory[T fs.InodeEmbedder] func(*git.Repository) T
 
 func Initialize[T fs.InodeEmbedder](t *testing.T, factory rootNodeFactory[T]) string {
+	t.Helper()
+	return InitializePrepared(t, factory, nil)
+}
+
+// InitializePrepared is like Initialize, but calls prepare with the path of the unzipped
+// test repository before mounting, so the test can add objects and references it needs.
+func InitializePrepared[T fs.InodeEmbedder](
+	t *testing.T,
+	factory rootNodeFactory[T],
+	prepare func(repoPath string) error,
+) string {
 	t.Helper()
 	repoPath, err := unzipTestRepository(t, testRepositoryZip)
 	if err != nil {
 		t.Fatalf("failed to unzip test repository: %v", err)
 	}
+	if prepare != nil {
+		err = prepare(repoPath)
+		if err != nil {
+			t.Fatalf("failed to prepare test repository: %v", err)
+		}
+	}
 	mountPoint, err := createMountPoint(t)
 	if err != nil {
 		t.Fatalf("failed to create mount point: %v", err)
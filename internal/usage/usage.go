@@ -0,0 +1,152 @@
+// Package usage measures the disk usage of git repository objects.
+package usage
+
+import (
+	"fmt"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v5/storage"
+	"os"
+	"path"
+)
+
+const (
+	objectsDir = "objects"
+	packDir    = "pack"
+	packExt    = ".pack"
+	idxExt     = ".idx"
+)
+
+// Usage is the disk usage of repository objects.
+type Usage struct {
+	// Size is the total size of pack files and loose objects in bytes.
+	Size uint64
+	// Objects is the number of objects in pack files plus the number of loose objects.
+	// Objects stored both packed and loose are counted twice, the same as `git count-objects` does.
+	Objects uint64
+}
+
+// filesystemStorer is implemented by storages keeping the repository on a filesystem.
+type filesystemStorer interface {
+	Filesystem() billy.Filesystem
+}
+
+// alternatesStorer is implemented by storages reading objects from the alternate object directories.
+type alternatesStorer interface {
+	AlternateFilesystems() []billy.Filesystem
+}
+
+// Read measures the disk usage of the storer objects.
+// Objects of the alternate object directories the storer reads are added up,
+// since they are the objects of the repository too.
+// Objects of storages not keeping the repository on a filesystem, for example, the memory storage,
+// are iterated and their sizes are summed up.
+func Read(storer storage.Storer) (Usage, error) {
+	fsStorer, ok := storer.(filesystemStorer)
+	if !ok {
+		return readObjects(storer)
+	}
+	filesystems := []billy.Filesystem{fsStorer.Filesystem()}
+	if alternates, ok := storer.(alternatesStorer); ok {
+		filesystems = append(filesystems, alternates.AlternateFilesystems()...)
+	}
+	var usage Usage
+	for _, fs := range filesystems {
+		if err := readPacks(fs, &usage); err != nil {
+			return Usage{}, err
+		}
+		if err := readLooseObjects(fs, &usage); err != nil {
+			return Usage{}, err
+		}
+	}
+	return usage, nil
+}
+
+func readObjects(storer storage.Storer) (Usage, error) {
+	objects, err := storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return Usage{}, fmt.Errorf("iterate objects: %w", err)
+	}
+	var usage Usage
+	err = objects.ForEach(func(obj plumbing.EncodedObject) error {
+		usage.Size += uint64(obj.Size())
+		usage.Objects++
+		return nil
+	})
+	if err != nil {
+		return Usage{}, fmt.Errorf("iterate objects: %w", err)
+	}
+	return usage, nil
+}
+
+// readPacks sums up the sizes of pack files and counts objects in their indexes.
+func readPacks(fs billy.Filesystem, usage *Usage) error {
+	dir := path.Join(objectsDir, packDir)
+	files, err := fs.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read pack dir: %w", err)
+	}
+	for _, file := range files {
+		switch path.Ext(file.Name()) {
+		case packExt:
+			usage.Size += uint64(file.Size())
+		case idxExt:
+			count, err := countIndexObjects(fs, path.Join(dir, file.Name()))
+			if err != nil {
+				return err
+			}
+			usage.Objects += count
+		}
+	}
+	return nil
+}
+
+func countIndexObjects(fs billy.Filesystem, filename string) (uint64, error) {
+	file, err := fs.Open(filename)
+	if err != nil {
+		return 0, fmt.Errorf("open pack index: %w", err)
+	}
+	defer file.Close()
+	index := idxfile.NewMemoryIndex()
+	if err := idxfile.NewDecoder(file).Decode(index); err != nil {
+		return 0, fmt.Errorf("decode pack index %s: %w", filename, err)
+	}
+	count, err := index.Count()
+	if err != nil {
+		return 0, fmt.Errorf("count pack index %s: %w", filename, err)
+	}
+	return uint64(count), nil
+}
+
+// readLooseObjects sums up the sizes and counts loose objects stored in
+// objects/xx/yyyy... files, where xxyyyy... is the object hash.
+func readLooseObjects(fs billy.Filesystem, usage *Usage) error {
+	dirs, err := fs.ReadDir(objectsDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read objects dir: %w", err)
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := fs.ReadDir(path.Join(objectsDir, dir.Name()))
+		if err != nil {
+			return fmt.Errorf("read objects dir: %w", err)
+		}
+		for _, file := range files {
+			if file.IsDir() || !plumbing.IsHash(dir.Name()+file.Name()) {
+				continue
+			}
+			usage.Size += uint64(file.Size())
+			usage.Objects++
+		}
+	}
+	return nil
+}
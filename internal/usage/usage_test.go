@@ -0,0 +1,124 @@
+package usage
+
+import (
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		storer := memory.NewStorage()
+		writeBlobs(t, storer, "first\n", "second\n")
+
+		usage, err := Read(storer)
+		require.NoError(t, err)
+		require.Equal(t, Usage{Size: 13, Objects: 2}, usage)
+	})
+
+	t.Run("loose objects", func(t *testing.T) {
+		storer := filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault())
+		writeBlobs(t, storer, "first\n", "second\n")
+
+		usage, err := Read(storer)
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), usage.Objects)
+		require.NotZero(t, usage.Size)
+	})
+
+	t.Run("packed objects", func(t *testing.T) {
+		fs := memfs.New()
+		storer := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+		repository, err := git.Init(storer, nil)
+		require.NoError(t, err)
+		blobs := writeBlobs(t, storer, "first\n")
+		tree := writeObject(t, storer, &object.Tree{Entries: []object.TreeEntry{
+			{Name: "first", Mode: filemode.Regular, Hash: blobs[0]},
+		}})
+		signature := object.Signature{Name: "gitfs", Email: "gitfs@example.com", When: time.Unix(1681128000, 0)}
+		commit := writeObject(t, storer, &object.Commit{
+			Author:    signature,
+			Committer: signature,
+			Message:   "initial commit\n",
+			TreeHash:  tree,
+		})
+		require.NoError(t, storer.SetReference(plumbing.NewHashReference(plumbing.Master, commit)))
+		require.NoError(t, repository.RepackObjects(&git.RepackConfig{}))
+
+		usage, err := Read(storer)
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), usage.Objects)
+		packs, err := fs.ReadDir("objects/pack")
+		require.NoError(t, err)
+		var packSize uint64
+		for _, pack := range packs {
+			if strings.HasSuffix(pack.Name(), ".pack") {
+				packSize += uint64(pack.Size())
+			}
+		}
+		require.NotZero(t, packSize)
+		require.Equal(t, packSize, usage.Size)
+	})
+
+	t.Run("alternates", func(t *testing.T) {
+		alternate := filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault())
+		writeBlobs(t, alternate, "first\n", "second\n")
+		storer := &alternatesStorage{
+			Storage:     filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault()),
+			filesystems: []billy.Filesystem{alternate.Filesystem()},
+		}
+		writeBlobs(t, storer, "third\n")
+
+		usage, err := Read(storer)
+		require.NoError(t, err)
+		require.Equal(t, uint64(3), usage.Objects)
+	})
+}
+
+// alternatesStorage is the storage reading objects from the alternate object directories.
+type alternatesStorage struct {
+	*filesystem.Storage
+	filesystems []billy.Filesystem
+}
+
+func (s *alternatesStorage) AlternateFilesystems() []billy.Filesystem {
+	return s.filesystems
+}
+
+func writeObject(t *testing.T, storer storage.Storer, obj interface {
+	Encode(plumbing.EncodedObject) error
+}) plumbing.Hash {
+	encoded := storer.NewEncodedObject()
+	require.NoError(t, obj.Encode(encoded))
+	hash, err := storer.SetEncodedObject(encoded)
+	require.NoError(t, err)
+	return hash
+}
+
+func writeBlobs(t *testing.T, storer storage.Storer, contents ...string) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	for _, content := range contents {
+		obj := storer.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		writer, err := obj.Writer()
+		require.NoError(t, err)
+		_, err = writer.Write([]byte(content))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+		hash, err := storer.SetEncodedObject(obj)
+		require.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+	return hashes
+}
@@ -0,0 +1,174 @@
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+	"hash"
+	"io"
+	"slices"
+	"strings"
+)
+
+// gitNamespace is the namespace git uses for SSH signatures of commits and tags.
+const gitNamespace = "git"
+
+const (
+	sshSignatureMagic   = "SSHSIG"
+	sshSignatureVersion = 1
+	sshSignaturePEMType = "SSH SIGNATURE"
+)
+
+// SSHSignature is a signature in the format of `ssh-keygen -Y sign`,
+// described in PROTOCOL.sshsig of OpenSSH.
+type SSHSignature struct {
+	PublicKey     ssh.PublicKey
+	Namespace     string
+	HashAlgorithm string
+	Signature     *ssh.Signature
+}
+
+type sshSignatureBlob struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// ParseSSHSignature parses the armored SSH signature.
+func ParseSSHSignature(armored []byte) (*SSHSignature, error) {
+	block, _ := pem.Decode(armored)
+	if block == nil || block.Type != sshSignaturePEMType {
+		return nil, errors.New("ssh signature: invalid armor")
+	}
+	data, ok := bytes.CutPrefix(block.Bytes, []byte(sshSignatureMagic))
+	if !ok {
+		return nil, errors.New("ssh signature: invalid magic preamble")
+	}
+	var blob sshSignatureBlob
+	if err := ssh.Unmarshal(data, &blob); err != nil {
+		return nil, fmt.Errorf("ssh signature: %w", err)
+	}
+	if blob.Version != sshSignatureVersion {
+		return nil, fmt.Errorf("ssh signature: unsupported version %d", blob.Version)
+	}
+	publicKey, err := ssh.ParsePublicKey(blob.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("ssh signature: public key: %w", err)
+	}
+	signature := &ssh.Signature{}
+	if err := ssh.Unmarshal(blob.Signature, signature); err != nil {
+		return nil, fmt.Errorf("ssh signature: signature: %w", err)
+	}
+	return &SSHSignature{
+		PublicKey:     publicKey,
+		Namespace:     blob.Namespace,
+		HashAlgorithm: blob.HashAlgorithm,
+		Signature:     signature,
+	}, nil
+}
+
+// Verify verifies the signature of the message made in the namespace by the signature public key.
+func (signature *SSHSignature) Verify(message []byte, namespace string) error {
+	if signature.Namespace != namespace {
+		return fmt.Errorf("ssh signature: namespace %q, expected %q", signature.Namespace, namespace)
+	}
+	var h hash.Hash
+	switch signature.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("ssh signature: unsupported hash algorithm %q", signature.HashAlgorithm)
+	}
+	h.Write(message)
+	signedData := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     signature.Namespace,
+		HashAlgorithm: signature.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+	if err := signature.PublicKey.Verify(signedData, signature.Signature); err != nil {
+		return fmt.Errorf("ssh signature: %w", err)
+	}
+	return nil
+}
+
+// AllowedSigner is an entry of the SSH allowed signers file.
+type AllowedSigner struct {
+	Principals []string
+	// Namespaces restricts the namespaces the key is allowed to sign in, empty means any.
+	Namespaces []string
+	PublicKey  ssh.PublicKey
+}
+
+// ParseAllowedSigners parses the allowed signers file in the format of ssh-keygen(1):
+// one entry per line with comma-separated principals, optional options and the public key.
+// Certificate authority entries are not supported and skipped.
+func ParseAllowedSigners(r io.Reader) ([]AllowedSigner, error) {
+	var signers []AllowedSigner
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		principals, rest, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing public key", lineNumber)
+		}
+		publicKey, _, options, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(rest)))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		signer := AllowedSigner{Principals: strings.Split(principals, ","), PublicKey: publicKey}
+		skip := false
+		for _, option := range options {
+			name, value, _ := strings.Cut(option, "=")
+			switch strings.ToLower(name) {
+			case "cert-authority":
+				skip = true
+			case "namespaces":
+				signer.Namespaces = strings.Split(strings.Trim(value, `"`), ",")
+			}
+		}
+		if !skip {
+			signers = append(signers, signer)
+		}
+	}
+	return signers, scanner.Err()
+}
+
+// findPrincipals returns principals allowed to sign in the namespace by the public key.
+func findPrincipals(signers []AllowedSigner, publicKey ssh.PublicKey, namespace string) []string {
+	marshaled := publicKey.Marshal()
+	var principals []string
+	for _, signer := range signers {
+		if !bytes.Equal(signer.PublicKey.Marshal(), marshaled) {
+			continue
+		}
+		if len(signer.Namespaces) > 0 && !slices.Contains(signer.Namespaces, namespace) {
+			continue
+		}
+		principals = append(principals, signer.Principals...)
+	}
+	return principals
+}
+
+func sshFingerprint(publicKey ssh.PublicKey) string {
+	return ssh.FingerprintSHA256(publicKey)
+}
@@ -0,0 +1,211 @@
+package verify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Status is the result of the signature verification.
+type Status string
+
+const (
+	// StatusGood means the signature is valid and made by a trusted key.
+	StatusGood Status = "good"
+	// StatusBad means the signature doesn't match the signed object.
+	StatusBad Status = "bad"
+	// StatusUnknownKey means the signature is made by a key which is not in the keyring or allowed signers.
+	StatusUnknownKey Status = "unknown-key"
+	// StatusUnsupported means the signature format can't be verified, for example, X509 signature.
+	StatusUnsupported Status = "unsupported"
+	// StatusUnsigned means the object has no signature.
+	StatusUnsigned Status = "unsigned"
+)
+
+// Signature types.
+const (
+	TypePGP = "pgp"
+	TypeSSH = "ssh"
+)
+
+const (
+	pgpSignaturePrefix = "-----BEGIN PGP SIGNATURE-----"
+	sshSignaturePrefix = "-----BEGIN SSH SIGNATURE-----"
+)
+
+// Result describes the signature verification result.
+type Result struct {
+	Status Status
+	// Type is the signature type, TypePGP or TypeSSH.
+	Type string
+	// Signer is the identity of the PGP key or the principal of the SSH key that made the signature.
+	Signer string
+	// Key is the fingerprint of the key that made the signature.
+	Key string
+	// Err is the verification error for bad, unknown-key and unsupported signatures.
+	Err error
+}
+
+// String formats the result as "key: value" lines.
+func (result Result) String() string {
+	var builder strings.Builder
+	_, _ = fmt.Fprintf(&builder, "status: %s\n", result.Status)
+	if result.Type != "" {
+		_, _ = fmt.Fprintf(&builder, "type: %s\n", result.Type)
+	}
+	if result.Signer != "" {
+		_, _ = fmt.Fprintf(&builder, "signer: %s\n", result.Signer)
+	}
+	if result.Key != "" {
+		_, _ = fmt.Fprintf(&builder, "key: %s\n", result.Key)
+	}
+	if result.Err != nil {
+		_, _ = fmt.Fprintf(&builder, "error: %s\n", result.Err)
+	}
+	return builder.String()
+}
+
+// Verifier verifies signatures of commits and tags against the trusted PGP keyring
+// and SSH allowed signers. Results are cached by object hashes, since objects never change.
+type Verifier struct {
+	keyring        openpgp.EntityList
+	allowedSigners []AllowedSigner
+	results        sync.Map
+}
+
+// NewVerifier creates a new Verifier.
+// The keyringPath is a path to an armored PGP keyring, the allowedSignersPath is a path to
+// an SSH allowed signers file in the format of ssh-keygen(1), both are optional.
+func NewVerifier(keyringPath, allowedSignersPath string) (*Verifier, error) {
+	verifier := &Verifier{}
+	if keyringPath != "" {
+		file, err := os.Open(keyringPath)
+		if err != nil {
+			return nil, fmt.Errorf("open keyring: %w", err)
+		}
+		defer file.Close()
+		verifier.keyring, err = openpgp.ReadArmoredKeyRing(file)
+		if err != nil {
+			return nil, fmt.Errorf("read keyring: %w", err)
+		}
+	}
+	if allowedSignersPath != "" {
+		file, err := os.Open(allowedSignersPath)
+		if err != nil {
+			return nil, fmt.Errorf("open allowed signers: %w", err)
+		}
+		defer file.Close()
+		verifier.allowedSigners, err = ParseAllowedSigners(file)
+		if err != nil {
+			return nil, fmt.Errorf("read allowed signers: %w", err)
+		}
+	}
+	return verifier, nil
+}
+
+// VerifyCommit verifies the commit signature.
+func (verifier *Verifier) VerifyCommit(commit *object.Commit) Result {
+	return verifier.cached(commit.Hash, func() Result {
+		payload := &plumbing.MemoryObject{}
+		if err := commit.EncodeWithoutSignature(payload); err != nil {
+			return Result{Status: StatusBad, Err: err}
+		}
+		return verifier.verify(payload, commit.PGPSignature)
+	})
+}
+
+// VerifyTag verifies the annotated tag signature.
+func (verifier *Verifier) VerifyTag(tag *object.Tag) Result {
+	return verifier.cached(tag.Hash, func() Result {
+		payload := &plumbing.MemoryObject{}
+		if err := tag.EncodeWithoutSignature(payload); err != nil {
+			return Result{Status: StatusBad, Err: err}
+		}
+		return verifier.verify(payload, tag.PGPSignature)
+	})
+}
+
+func (verifier *Verifier) cached(hash plumbing.Hash, verify func() Result) Result {
+	if result, ok := verifier.results.Load(hash); ok {
+		return result.(Result)
+	}
+	result := verify()
+	verifier.results.Store(hash, result)
+	return result
+}
+
+func (verifier *Verifier) verify(payload *plumbing.MemoryObject, signature string) Result {
+	reader, err := payload.Reader()
+	if err != nil {
+		return Result{Status: StatusBad, Err: err}
+	}
+	defer reader.Close()
+	message, err := io.ReadAll(reader)
+	if err != nil {
+		return Result{Status: StatusBad, Err: err}
+	}
+
+	switch {
+	case signature == "":
+		return Result{Status: StatusUnsigned}
+	case strings.HasPrefix(signature, pgpSignaturePrefix):
+		return verifier.verifyPGP(message, signature)
+	case strings.HasPrefix(signature, sshSignaturePrefix):
+		return verifier.verifySSH(message, signature)
+	default:
+		return Result{Status: StatusUnsupported, Err: errors.New("unsupported signature format")}
+	}
+}
+
+func (verifier *Verifier) verifyPGP(message []byte, signature string) Result {
+	entity, err := openpgp.CheckArmoredDetachedSignature(
+		verifier.keyring,
+		bytes.NewReader(message),
+		strings.NewReader(signature),
+		nil,
+	)
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		return Result{Status: StatusUnknownKey, Type: TypePGP, Err: err}
+	}
+	if err != nil {
+		return Result{Status: StatusBad, Type: TypePGP, Err: err}
+	}
+	result := Result{
+		Status: StatusGood,
+		Type:   TypePGP,
+		Key:    fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint),
+	}
+	if identity := entity.PrimaryIdentity(); identity != nil {
+		result.Signer = identity.Name
+	}
+	return result
+}
+
+func (verifier *Verifier) verifySSH(message []byte, signature string) Result {
+	sig, err := ParseSSHSignature([]byte(signature))
+	if err != nil {
+		return Result{Status: StatusBad, Type: TypeSSH, Err: err}
+	}
+	key := sshFingerprint(sig.PublicKey)
+	if err := sig.Verify(message, gitNamespace); err != nil {
+		return Result{Status: StatusBad, Type: TypeSSH, Key: key, Err: err}
+	}
+	principals := findPrincipals(verifier.allowedSigners, sig.PublicKey, gitNamespace)
+	if len(principals) == 0 {
+		return Result{
+			Status: StatusUnknownKey,
+			Type:   TypeSSH,
+			Key:    key,
+			Err:    errors.New("key is not in allowed signers"),
+		}
+	}
+	return Result{Status: StatusGood, Type: TypeSSH, Signer: strings.Join(principals, ","), Key: key}
+}
@@ -0,0 +1,155 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyCommitPGP(t *testing.T) {
+	trusted, err := openpgp.NewEntity("Trusted", "", "trusted@example.com", nil)
+	require.NoError(t, err)
+	untrusted, err := openpgp.NewEntity("Untrusted", "", "untrusted@example.com", nil)
+	require.NoError(t, err)
+
+	var keyring bytes.Buffer
+	writer, err := armor.Encode(&keyring, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, trusted.Serialize(writer))
+	require.NoError(t, writer.Close())
+	verifier, err := NewVerifier(writeFile(t, "keyring.asc", keyring.String()), "")
+	require.NoError(t, err)
+
+	commit := testCommit("signed commit\n")
+	commit.PGPSignature = pgpSign(t, trusted, commit)
+	result := verifier.VerifyCommit(commit)
+	require.Equal(t, StatusGood, result.Status, result.Err)
+	require.Equal(t, TypePGP, result.Type)
+	require.Equal(t, "Trusted <trusted@example.com>", result.Signer)
+
+	commit = testCommit("tampered commit\n")
+	commit.PGPSignature = pgpSign(t, trusted, testCommit("original commit\n"))
+	require.Equal(t, StatusBad, verifier.VerifyCommit(commit).Status)
+
+	commit = testCommit("untrusted commit\n")
+	commit.PGPSignature = pgpSign(t, untrusted, commit)
+	require.Equal(t, StatusUnknownKey, verifier.VerifyCommit(commit).Status)
+
+	require.Equal(t, StatusUnsigned, verifier.VerifyCommit(testCommit("unsigned commit\n")).Status)
+}
+
+func TestVerifyTagSSH(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	require.NoError(t, err)
+	_, otherPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	allowedSigners := "# comment\n" +
+		`alice@example.com,alice namespaces="git" ` + string(ssh.MarshalAuthorizedKey(sshPublicKey))
+	verifier, err := NewVerifier("", writeFile(t, "allowed_signers", allowedSigners))
+	require.NoError(t, err)
+
+	tag := testTag("signed tag\n")
+	tag.PGPSignature = sshSign(t, privateKey, tag, gitNamespace)
+	result := verifier.VerifyTag(tag)
+	require.Equal(t, StatusGood, result.Status, result.Err)
+	require.Equal(t, TypeSSH, result.Type)
+	require.Equal(t, "alice@example.com,alice", result.Signer)
+	require.Equal(t, ssh.FingerprintSHA256(sshPublicKey), result.Key)
+
+	tag = testTag("other namespace tag\n")
+	tag.PGPSignature = sshSign(t, privateKey, tag, "file")
+	require.Equal(t, StatusBad, verifier.VerifyTag(tag).Status)
+
+	tag = testTag("unknown key tag\n")
+	tag.PGPSignature = sshSign(t, otherPrivateKey, tag, gitNamespace)
+	require.Equal(t, StatusUnknownKey, verifier.VerifyTag(tag).Status)
+}
+
+func testCommit(message string) *object.Commit {
+	signature := object.Signature{Name: "gitfs", Email: "gitfs@example.com", When: time.Unix(1681128000, 0).UTC()}
+	commit := &object.Commit{
+		Author:    signature,
+		Committer: signature,
+		Message:   message,
+		TreeHash:  plumbing.NewHash("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+	}
+	commit.Hash = plumbing.ComputeHash(plumbing.CommitObject, []byte(message))
+	return commit
+}
+
+func testTag(message string) *object.Tag {
+	tag := &object.Tag{
+		Name:       "v1.0.0",
+		Tagger:     object.Signature{Name: "gitfs", Email: "gitfs@example.com", When: time.Unix(1681128000, 0).UTC()},
+		Message:    message,
+		TargetType: plumbing.CommitObject,
+		Target:     plumbing.NewHash("3991e5a92b70e6a4e91ce48d2165a92b8b056cdd"),
+	}
+	tag.Hash = plumbing.ComputeHash(plumbing.TagObject, []byte(message))
+	return tag
+}
+
+type signable interface {
+	EncodeWithoutSignature(o plumbing.EncodedObject) error
+}
+
+func payload(t *testing.T, obj signable) []byte {
+	encoded := &plumbing.MemoryObject{}
+	require.NoError(t, obj.EncodeWithoutSignature(encoded))
+	reader, err := encoded.Reader()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(reader)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func pgpSign(t *testing.T, signer *openpgp.Entity, obj signable) string {
+	var signature strings.Builder
+	require.NoError(t, openpgp.ArmoredDetachSign(&signature, signer, bytes.NewReader(payload(t, obj)), nil))
+	return signature.String() + "\n"
+}
+
+// sshSign signs the object the same way as `ssh-keygen -Y sign -n <namespace>` does.
+func sshSign(t *testing.T, privateKey ed25519.PrivateKey, obj signable, namespace string) string {
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	require.NoError(t, err)
+	hash := sha512.Sum512(payload(t, obj))
+	signedData := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     namespace,
+		HashAlgorithm: "sha512",
+		Hash:          hash[:],
+	})...)
+	signature, err := signer.Sign(rand.Reader, signedData)
+	require.NoError(t, err)
+	blob := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignatureBlob{
+		Version:       sshSignatureVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     namespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh.Marshal(signature),
+	})...)
+	return string(pem.EncodeToMemory(&pem.Block{Type: sshSignaturePEMType, Bytes: blob}))
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
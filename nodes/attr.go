@@ -0,0 +1,52 @@
+package nodes
+
+import (
+	"context"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"syscall"
+)
+
+const (
+	// dirPermissions are the permissions of directories, the filesystem is read-only.
+	dirPermissions = 0555
+	// dirSize is the size reported for directories, the same as a single block directory has on ext4.
+	// The number of blocks is derived from the size by go-fuse.
+	dirSize = 4096
+)
+
+// setDirAttr sets the attributes of the directory having the number of subdirectories.
+// The link count of a directory is 2 (the entry in the parent and ".") plus ".." of each subdirectory.
+func setDirAttr(out *fuse.Attr, subdirs int) {
+	out.Mode = syscall.S_IFDIR | dirPermissions
+	out.Nlink = uint32(2 + subdirs)
+	out.Size = dirSize
+}
+
+// setUncountedDirAttr sets the attributes of the directory too large to count its subdirectories.
+// The link count 1 tells tools like find that the number of subdirectories is unknown.
+func setUncountedDirAttr(out *fuse.Attr) {
+	setDirAttr(out, 0)
+	out.Nlink = 1
+}
+
+// setReaddirAttr sets the attributes of the directory counting the subdirectories listed by the node.
+func setReaddirAttr(ctx context.Context, node fs.NodeReaddirer, out *fuse.Attr) syscall.Errno {
+	stream, errno := node.Readdir(ctx)
+	if errno != 0 {
+		return errno
+	}
+	defer stream.Close()
+	subdirs := 0
+	for stream.HasNext() {
+		entry, errno := stream.Next()
+		if errno != 0 {
+			return errno
+		}
+		if entry.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+			subdirs++
+		}
+	}
+	setDirAttr(out, subdirs)
+	return 0
+}
@@ -0,0 +1,39 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestDirAttrs(t *testing.T) {
+	mountPoint := testdata.Initialize(t, NewRootNode)
+
+	tests := []struct {
+		path  string
+		nlink uint64
+	}{
+		{path: "", nlink: 10},
+		{path: "branches", nlink: 5},
+		{path: "branches/nested/dir", nlink: 3},
+		{path: "commits", nlink: 1},
+		{path: "commits/" + commits[0], nlink: 2},
+		{path: "commits/" + commits[3], nlink: 3},
+		{path: "commits/" + commits[3] + "/testdir", nlink: 2},
+		{path: "index", nlink: 3},
+		{path: "tags", nlink: 1},
+	}
+	for _, test := range tests {
+		info, err := os.Stat(filepath.Join(mountPoint, test.path))
+		require.NoError(t, err, test.path)
+		require.True(t, info.IsDir(), test.path)
+		require.Equal(t, os.FileMode(0555), info.Mode().Perm(), test.path)
+		stat := info.Sys().(*syscall.Stat_t)
+		require.Equal(t, test.nlink, uint64(stat.Nlink), test.path)
+		require.Equal(t, int64(dirSize), info.Size(), test.path)
+		require.Equal(t, int64(dirSize/512), stat.Blocks, test.path)
+	}
+}
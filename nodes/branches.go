@@ -2,9 +2,8 @@ package nodes
 
 import (
 	"context"
-	"github.com/dsxack/gitfs/internal/iter"
+	"github.com/dsxack/gitfs/internal/refindex"
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"log/slog"
@@ -17,6 +16,7 @@ var (
 	_ fs.InodeEmbedder = (*BranchesNode)(nil)
 	_ fs.NodeReaddirer = (*BranchesNode)(nil)
 	_ fs.NodeLookuper  = (*BranchesNode)(nil)
+	_ fs.NodeGetattrer = (*BranchesNode)(nil)
 )
 
 const branchNameSeparator = string(filepath.Separator)
@@ -29,64 +29,111 @@ const branchNameSeparator = string(filepath.Separator)
 type BranchesNode struct {
 	fs.Inode
 	repository *git.Repository
+	options    *Options
 }
 
 // NewBranchesNode creates a new BranchesNode.
-func NewBranchesNode(repository *git.Repository) *BranchesNode {
-	return &BranchesNode{repository: repository}
+func NewBranchesNode(repository *git.Repository, options *Options) *BranchesNode {
+	return &BranchesNode{repository: repository, options: options}
 }
 
 // Lookup returns a branch commit three node or a branch segment node.
 // If branch name is "foo", it will return a branch commit tree node.
 // If branch name is "foo/bar", it will return a branch segment node with name "bar".
 // It returns ENOENT if the name is not found.
-func (node *BranchesNode) Lookup(ctx context.Context, name string, _ *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	logger := slog.Default().With(slog.String("lookupBranchName", name))
-	revision := revisionBranchName(name)
-	branches, err := node.repository.Branches()
+func (node *BranchesNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return lookupBranch(ctx, &node.Inode, out, node.repository, node.options, "", name)
+}
+
+// Readdir returns a list of branches.
+// If branch contains directory separator, it will be split into segments and each segment will be a nested directory.
+func (node *BranchesNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	return readBranchesDir(&node.Inode, node.repository, node.options, "")
+}
+
+// lookupBranch looks up the child of the branch directory with the branch prefix.
+func lookupBranch(
+	ctx context.Context,
+	parent *fs.Inode,
+	out *fuse.EntryOut,
+	repository *git.Repository,
+	options *Options,
+	branchPrefix string,
+	name string,
+) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().
+		With(slog.String("lookupBranchName", name)).
+		With(slog.String("branchPrefix", branchPrefix))
+	revision := revisionBranchName(branchPrefix + name)
+	refNode, err := options.referenceIndex(repository).Lookup(revision)
 	if err != nil {
 		logger.Error("Error lookup branch", slog.String("error", err.Error()))
 		return nil, syscall.ENOENT
 	}
-	ok, hasPrefix := iter.HasReference(branches, revision)
-	if ok {
-		branchNode, err := NewObjectTreeNodeByRevision(node.repository, revision)
+	if refNode == nil {
+		logger.Info("No branch found")
+		return nil, syscall.ENOENT
+	}
+	if refNode.Reference() != nil {
+		branchNode, err := NewObjectTreeNodeByRevision(repository, options, revision)
 		if err != nil {
 			logger.Error("Error lookup branch object tree", slog.String("error", err.Error()))
 			return nil, syscall.ENOENT
 		}
 		logger.Info("Branch object tree found")
-		return node.NewInode(ctx, branchNode, fs.StableAttr{Mode: syscall.S_IFDIR}), 0
-	}
-	if !hasPrefix {
-		logger.Info("No branch found")
-		return nil, syscall.ENOENT
+		return newEntryInode(ctx, parent, out, branchNode, options.commitStableAttr(parent, name, branchNode.commit.Hash)), 0
 	}
 	logger.Info("Branch segment found")
 
-	return node.NewInode(
+	return newUncountedDirEntryInode(
 		ctx,
-		NewBranchSegmentNode(node.repository, name+branchNameSeparator),
-		fs.StableAttr{Mode: syscall.S_IFDIR},
+		parent,
+		out,
+		NewBranchSegmentNode(repository, options, branchPrefix+name+branchNameSeparator),
+		options.pathStableAttr(parent, name),
 	), 0
 }
 
-// Readdir returns a list of branches.
-// If branch contains directory separator, it will be split into segments and each segment will be a nested directory.
-func (node *BranchesNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
-	branches, err := node.repository.Branches()
+// readBranchesDir returns the entries of the branch directory with the branch prefix, sorted by name.
+// Both branches and segments of branch names are directories, each listed once.
+func readBranchesDir(
+	parent *fs.Inode,
+	repository *git.Repository,
+	options *Options,
+	branchPrefix string,
+) (fs.DirStream, syscall.Errno) {
+	refNode, err := options.referenceIndex(repository).Lookup(strings.TrimSuffix(revisionBranchName(branchPrefix), branchNameSeparator))
 	if err != nil {
 		return nil, syscall.ENOENT
 	}
-	slog.Default().Info("Dir of repository branches has been read")
-	return iter.NewDirStreamAdapter[*plumbing.Reference](
-		branches,
-		func(branchRef *plumbing.Reference) fuse.DirEntry {
-			name := bareBranchName(branchRef.Name().String())
-			segments := strings.Split(name, branchNameSeparator)
-			return fuse.DirEntry{Name: segments[0], Mode: syscall.S_IFDIR}
-		},
-	), 0
+	var entries []fuse.DirEntry
+	if refNode != nil {
+		for _, child := range refNode.Children() {
+			entries = append(entries, branchDirEntry(parent, repository, options, child))
+		}
+	}
+	slog.Default().Info("Dir of repository branches has been read", slog.String("branchPrefix", branchPrefix))
+	return fs.NewListDirStream(entries), 0
+}
+
+// branchDirEntry returns the directory entry of the child of the branch directory,
+// with the inode number the child has when it is looked up.
+// The child is a branch if it is a reference, even if there are branches nested in it,
+// the same as the lookup prefers the branch.
+func branchDirEntry(parent *fs.Inode, repository *git.Repository, options *Options, child refindex.Child) fuse.DirEntry {
+	entry := fuse.DirEntry{Name: child.Name, Mode: syscall.S_IFDIR}
+	branchRef := child.Node.Reference()
+	if branchRef == nil {
+		entry.Ino = options.inodeTable().peek(pathInoKey(parent, child.Name))
+		return entry
+	}
+	ref, err := repository.Reference(branchRef.Name(), true)
+	if err != nil {
+		slog.Default().Warn("Error resolve branch", slog.String("branch", branchRef.Name().String()), slog.String("error", err.Error()))
+		return entry
+	}
+	entry.Ino = options.inodeTable().peek(commitInoKey(ref.Hash(), parent, child.Name))
+	return entry
 }
 
 const revisionBranchPrefix = "refs/heads/"
@@ -98,3 +145,24 @@ func bareBranchName(revision string) string {
 func revisionBranchName(branch string) string {
 	return revisionBranchPrefix + branch
 }
+
+// Getattr returns the directory attributes.
+func (node *BranchesNode) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return setBranchesDirAttr(node.repository, node.options, "", &out.Attr)
+}
+
+// setBranchesDirAttr sets the attributes of the branch directory with the branch prefix.
+// Both branches and segments of branch names are directories, so the subdirectories are
+// the children of the prefix in the index of the references.
+func setBranchesDirAttr(repository *git.Repository, options *Options, branchPrefix string, out *fuse.Attr) syscall.Errno {
+	refNode, err := options.referenceIndex(repository).Lookup(strings.TrimSuffix(revisionBranchName(branchPrefix), branchNameSeparator))
+	if err != nil {
+		return syscall.ENOENT
+	}
+	subdirs := 0
+	if refNode != nil {
+		subdirs = refNode.NumChildren()
+	}
+	setDirAttr(out, subdirs)
+	return 0
+}
@@ -0,0 +1,69 @@
+package nodes
+
+import (
+	"context"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"time"
+)
+
+// MutableCacheTimeout is the kernel cache timeout of entries and attributes which change
+// when references or the index are updated, for example, branch directories.
+// It is meant to be used as the mount default entry and attribute timeout.
+const MutableCacheTimeout = time.Second
+
+// immutableCacheTimeout is the kernel cache timeout of entries and attributes which never change,
+// for example, files and directories of commit trees.
+const immutableCacheTimeout = time.Hour
+
+// setImmutableEntryTimeout makes the kernel cache the entry and its attributes for a long time.
+func setImmutableEntryTimeout(out *fuse.EntryOut) {
+	out.SetEntryTimeout(immutableCacheTimeout)
+	out.SetAttrTimeout(immutableCacheTimeout)
+}
+
+// setReferenceEntryTimeout makes the kernel cache the entry looked up through a reference
+// which is not expected to move, for example, a tag, for a long time if the references are watched,
+// since the entry is invalidated once the reference moves. Otherwise, the entry is cached
+// for the mount default MutableCacheTimeout, so the moved reference is seen shortly.
+func (options *Options) setReferenceEntryTimeout(out *fuse.EntryOut) {
+	if options != nil && options.ReferencesWatched {
+		setImmutableEntryTimeout(out)
+	}
+}
+
+// newEntryInode creates the inode of the node looked up in the parent.
+// The kernel caches the entry attributes together with the entry,
+// so they are filled from the node Getattr.
+func newEntryInode(
+	ctx context.Context,
+	parent *fs.Inode,
+	out *fuse.EntryOut,
+	node fs.InodeEmbedder,
+	attr fs.StableAttr,
+) *fs.Inode {
+	inode := parent.NewInode(ctx, node, attr)
+	if getattrer, ok := node.(fs.NodeGetattrer); ok {
+		var attrOut fuse.AttrOut
+		if errno := getattrer.Getattr(ctx, nil, &attrOut); errno == 0 {
+			out.Attr = attrOut.Attr
+		}
+	}
+	return inode
+}
+
+// newUncountedDirEntryInode creates the inode of the directory looked up in the parent
+// without calling its Getattr, which counts the subdirectories and is expensive,
+// for example, for the directories of many references. The entry attributes are not cached
+// by the kernel, so the directory is counted only once it is stat'ed, not when a path through it is resolved.
+func newUncountedDirEntryInode(
+	ctx context.Context,
+	parent *fs.Inode,
+	out *fuse.EntryOut,
+	node fs.InodeEmbedder,
+	attr fs.StableAttr,
+) *fs.Inode {
+	setUncountedDirAttr(&out.Attr)
+	out.SetAttrTimeout(0)
+	return parent.NewInode(ctx, node, attr)
+}
@@ -0,0 +1,33 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImmutableEntryAttrs(t *testing.T) {
+	mountPoint := testdata.Initialize(t, NewRootNode)
+	commitPath := filepath.Join(mountPoint, "commits", commits[3])
+
+	// The attributes cached together with the looked up entries must be the same as the node attributes.
+	info, err := os.Stat(filepath.Join(commitPath, "testdir"))
+	require.NoError(t, err)
+	require.True(t, info.IsDir())
+	require.Equal(t, os.FileMode(dirPermissions), info.Mode().Perm())
+
+	filePath := filepath.Join(commitPath, "testdir", "testfile4")
+	info, err = os.Stat(filePath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+
+	// The content is read twice, the second time from the kept page cache.
+	for i := 0; i < 2; i++ {
+		content, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		require.Equal(t, info.Size(), int64(len(content)))
+		require.NotEmpty(t, content)
+	}
+}
@@ -2,12 +2,17 @@ package nodes
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/dsxack/gitfs/internal/iter"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"log/slog"
+	"strings"
 	"syscall"
 )
 
@@ -15,48 +20,387 @@ var (
 	_ fs.InodeEmbedder = (*CommitsNode)(nil)
 	_ fs.NodeReaddirer = (*CommitsNode)(nil)
 	_ fs.NodeLookuper  = (*CommitsNode)(nil)
+	_ fs.NodeGetattrer = (*CommitsNode)(nil)
+)
+
+// Reachability selects commits by their reachability from the repository references.
+type Reachability int
+
+const (
+	// AllCommits selects every commit stored in the repository.
+	AllCommits Reachability = iota
+	// ReachableCommits selects commits reachable from any reference or HEAD.
+	ReachableCommits
+	// UnreachableCommits selects commits not reachable from any reference or HEAD,
+	// for example, commits lost after rebase or branch deletion.
+	UnreachableCommits
 )
 
 // CommitsNode is a filesystem node that represents a list of commits.
 // It is a child of the root node.
 // It is a directory.
 // It contains a list of directories, each directory represents a commit.
+// The listed and looked up commits are selected by reachability.
 type CommitsNode struct {
 	fs.Inode
-	repository *git.Repository
+	repository   *git.Repository
+	options      *Options
+	reachability Reachability
 }
 
 // NewCommitsNode creates a new CommitsNode.
-func NewCommitsNode(repository *git.Repository) *CommitsNode {
-	return &CommitsNode{repository: repository}
+func NewCommitsNode(repository *git.Repository, options *Options, reachability Reachability) *CommitsNode {
+	return &CommitsNode{repository: repository, options: options, reachability: reachability}
 }
 
 // Lookup looks up a commit by its hash.
 // It returns a directory that represents the commit.
+// If the name is the commit hash with ".note" suffix, it returns the commit note file
+// of the default notes reference (refs/notes/commits).
+// If the name is the commit hash with ".signature" suffix, it returns the commit signature
+// verification result when the verifier is configured.
+// Other revision names, for example, HEAD or branch names, are resolved to the commits
+// unless the node lists unreachable commits only, which are looked up by their full hashes only.
+// Commits not selected by the reachability of the node are not found.
+// Commits and their signatures never change, so the kernel caches the ones looked up by their hashes
+// for a long time, unless they are selected by the reachability.
 // It returns ENOENT if the name is not found.
-func (node *CommitsNode) Lookup(ctx context.Context, hash string, _ *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	logger := slog.Default().With(slog.String("lookupCommitHash", hash))
-	objectNode, err := NewObjectTreeNodeByRevision(node.repository, hash)
-	if err != nil {
-		logger.Warn("Error lookup commit object tree", slog.String("error", err.Error()))
+func (node *CommitsNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().With(slog.String("lookupCommitHash", name))
+	hash, isNote := strings.CutSuffix(name, noteFileSuffix)
+	isSignature := false
+	if !isNote && node.options.Verifier != nil {
+		hash, isSignature = strings.CutSuffix(name, signatureFileSuffix)
+	}
+	if isNote {
+		return node.lookupNote(ctx, hash, out, logger)
+	}
+	if isSignature {
+		return node.lookupSignature(ctx, hash, out, logger)
+	}
+	if node.reachability == UnreachableCommits && !plumbing.IsHash(hash) {
+		logger.Warn("Unreachable commit not found")
 		return nil, syscall.ENOENT
 	}
+	objectNode, err := node.newCommitTreeNode(hash)
+	if err != nil {
+		return nil, node.missingCommit(hash, logger, "Error lookup commit object tree", err)
+	}
+	if errno := node.checkSelected(objectNode.commit.Hash, logger); errno != 0 {
+		return nil, errno
+	}
 	logger.Info("Commit object tree found")
+	if node.isImmutable(hash) {
+		setImmutableEntryTimeout(out)
+	}
+
+	return newEntryInode(ctx, &node.Inode, out, objectNode, node.options.treeStableAttr(&node.Inode, name, objectNode.tree)), 0
+}
+
+// isImmutable reports whether the entry of the revision name never changes, so the kernel can cache it
+// for a long time. Other revision names, for example, HEAD or branch names, move to other commits,
+// and the commits selected by the reachability come and go as the references change.
+func (node *CommitsNode) isImmutable(revision string) bool {
+	return plumbing.IsHash(revision) && node.reachability == AllCommits
+}
+
+// newCommitTreeNode creates the node of the commit tree by the commit hash or by other revision name.
+// The commit looked up by its hash is read directly, without resolving the hash as a reference name.
+func (node *CommitsNode) newCommitTreeNode(revision string) (*ObjectTreeNode, error) {
+	if !plumbing.IsHash(revision) {
+		return NewObjectTreeNodeByRevision(node.repository, node.options, revision)
+	}
+	commit, err := node.repository.CommitObject(plumbing.NewHash(revision))
+	if err != nil {
+		return nil, fmt.Errorf("repository: commit object: %v", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("commit tree: %v", err)
+	}
+	return NewObjectTreeNode(node.repository, node.options, revision, commit, tree), nil
+}
 
-	return node.NewInode(ctx, objectNode, fs.StableAttr{Mode: syscall.S_IFDIR}), 0
+// checkSelected returns ENOENT if the commit is not selected by the reachability of the node.
+func (node *CommitsNode) checkSelected(hash plumbing.Hash, logger *slog.Logger) syscall.Errno {
+	if node.reachability == AllCommits {
+		return 0
+	}
+	reachable, err := node.options.reachableCommits(node.repository)
+	if err != nil {
+		logger.Error("Error lookup reachable commits", slog.String("error", err.Error()))
+		return syscall.ENOENT
+	}
+	if _, ok := reachable[hash]; ok != (node.reachability == ReachableCommits) {
+		logger.Warn("Commit is not selected by its reachability", slog.Bool("reachable", ok))
+		return syscall.ENOENT
+	}
+	return 0
+}
+
+func (node *CommitsNode) lookupNote(
+	ctx context.Context,
+	hash string,
+	out *fuse.EntryOut,
+	logger *slog.Logger,
+) (*fs.Inode, syscall.Errno) {
+	if !plumbing.IsHash(hash) {
+		logger.Warn("Commit note not found")
+		return nil, syscall.ENOENT
+	}
+	_, err := node.repository.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, node.missingCommit(hash, logger, "Error lookup commit", err)
+	}
+	if errno := node.checkSelected(plumbing.NewHash(hash), logger); errno != 0 {
+		return nil, errno
+	}
+	noteNode, err := readNote(node.repository, defaultNotesReferenceName, plumbing.NewHash(hash))
+	if err != nil {
+		logger.Warn("Error lookup commit note", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	logger.Info("Commit note found")
+
+	return newEntryInode(ctx, &node.Inode, out, noteNode, fs.StableAttr{Mode: syscall.S_IFREG}), 0
+}
+
+func (node *CommitsNode) lookupSignature(
+	ctx context.Context,
+	hash string,
+	out *fuse.EntryOut,
+	logger *slog.Logger,
+) (*fs.Inode, syscall.Errno) {
+	if !plumbing.IsHash(hash) {
+		logger.Warn("Commit signature not found")
+		return nil, syscall.ENOENT
+	}
+	commit, err := node.repository.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, node.missingCommit(hash, logger, "Error lookup commit", err)
+	}
+	if errno := node.checkSelected(commit.Hash, logger); errno != 0 {
+		return nil, errno
+	}
+	result := node.options.Verifier.VerifyCommit(commit)
+	logger.Info("Commit signature verified", slog.String("status", string(result.Status)))
+	if node.isImmutable(hash) {
+		setImmutableEntryTimeout(out)
+	}
+
+	return newEntryInode(
+		ctx,
+		&node.Inode,
+		out,
+		NewTextFileNode([]byte(result.String()), commit.Committer.When),
+		fs.StableAttr{Mode: syscall.S_IFREG},
+	), 0
+}
+
+// missingCommit logs the failed lookup of the commit and returns ENOENT.
+// The parents of the commits at the shallow boundary of a shallow clone are missing by design,
+// and tools following the history look them up over and over,
+// so their lookups are logged at the debug level only.
+func (node *CommitsNode) missingCommit(hash string, logger *slog.Logger, message string, err error) syscall.Errno {
+	if node.isShallowParent(hash) {
+		logger.Debug("Commit is beyond the shallow boundary")
+		return syscall.ENOENT
+	}
+	logger.Warn(message, slog.String("error", err.Error()))
+	return syscall.ENOENT
 }
 
 // Readdir reads the list of commits.
-// It returns a list of directories, each directory represents a commit.
+// It returns a list of directories, each directory represents a commit,
+// a signature verification file for each listed commit when the verifier is configured,
+// and a note file for each listed commit having a note.
 func (node *CommitsNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
-	commits, err := node.repository.CommitObjects()
+	var reachable map[plumbing.Hash]struct{}
+	selected := func(hash plumbing.Hash) bool {
+		if node.reachability == AllCommits {
+			return true
+		}
+		_, ok := reachable[hash]
+		return ok == (node.reachability == ReachableCommits)
+	}
+	if node.reachability != AllCommits {
+		var err error
+		reachable, err = node.options.reachableCommits(node.repository)
+		if err != nil {
+			slog.Default().Error("Error read reachable commits", slog.String("error", err.Error()))
+			return nil, syscall.ENOENT
+		}
+	}
+	notes, err := readNotes(node.repository, defaultNotesReferenceName)
 	if err != nil {
+		slog.Default().Error("Error read commit notes", slog.String("error", err.Error()))
 		return nil, syscall.ENOENT
 	}
+	var noteEntries []fuse.DirEntry
+	for hash := range notes.blobs {
+		_, err := node.repository.Storer.EncodedObject(plumbing.CommitObject, hash)
+		if err != nil || !selected(hash) {
+			continue
+		}
+		noteEntries = append(noteEntries, fuse.DirEntry{Name: hash.String() + noteFileSuffix, Mode: syscall.S_IFREG})
+	}
+
+	commits, err := node.selectedCommits(selected)
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+	streams := []fs.DirStream{
+		iter.NewDirStreamAdapter[*object.Commit](
+			commits, func(commit *object.Commit) fuse.DirEntry {
+				return fuse.DirEntry{Name: commit.Hash.String(), Mode: syscall.S_IFDIR}
+			},
+		),
+	}
+	if node.options.Verifier != nil {
+		signatureCommits, err := node.selectedCommits(selected)
+		if err != nil {
+			return nil, syscall.ENOENT
+		}
+		streams = append(streams, iter.NewDirStreamAdapter[*object.Commit](
+			signatureCommits, func(commit *object.Commit) fuse.DirEntry {
+				return fuse.DirEntry{Name: commit.Hash.String() + signatureFileSuffix, Mode: syscall.S_IFREG}
+			},
+		))
+	}
+	streams = append(streams, fs.NewListDirStream(noteEntries))
 	slog.Default().Info("Dir of repository commits has been read")
-	return iter.NewDirStreamAdapter[*object.Commit](
-		commits, func(commit *object.Commit) fuse.DirEntry {
-			return fuse.DirEntry{Name: commit.Hash.String(), Mode: syscall.S_IFDIR}
-		},
-	), 0
+	return iter.NewConcatDirStream(streams...), 0
+}
+
+func (node *CommitsNode) selectedCommits(selected func(hash plumbing.Hash) bool) (iter.Iter[*object.Commit], error) {
+	commits, err := node.repository.CommitObjects()
+	if err != nil {
+		return nil, err
+	}
+	return iter.NewFilterIter[*object.Commit](commits, func(commit *object.Commit) bool {
+		return selected(commit.Hash)
+	}), nil
+}
+
+// reachableCommits returns hashes of commits reachable from the repository references and HEAD,
+// cached until the references change.
+// Nodes created without the root node options compute the commits on every call.
+func (options *Options) reachableCommits(repository *git.Repository) (map[plumbing.Hash]struct{}, error) {
+	if options == nil || options.reachable == nil {
+		return reachableCommits(repository)
+	}
+	return options.reachable.get(options.referenceIndex(repository), func() (map[plumbing.Hash]struct{}, error) {
+		return reachableCommits(repository)
+	})
+}
+
+// reachableCommits returns hashes of commits reachable from the repository references and HEAD.
+// Reflogs are not taken into account, so commits referenced by reflogs only are unreachable.
+// Missing commits, for example, parents of shallow clone boundary commits, are skipped.
+func reachableCommits(repository *git.Repository) (map[plumbing.Hash]struct{}, error) {
+	refs, err := repository.Storer.IterReferences()
+	if err != nil {
+		return nil, fmt.Errorf("repository: references: %v", err)
+	}
+	var queue []plumbing.Hash
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.SymbolicReference {
+			ref, err = storer.ResolveReference(repository.Storer, ref.Name())
+			if err != nil {
+				return nil
+			}
+		}
+		queue = append(queue, ref.Hash())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("repository: references: %v", err)
+	}
+	head, err := repository.Head()
+	if err == nil {
+		queue = append(queue, head.Hash())
+	}
+
+	reachable := make(map[plumbing.Hash]struct{})
+	for len(queue) > 0 {
+		hash := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if _, ok := reachable[hash]; ok {
+			continue
+		}
+		obj, err := repository.Storer.EncodedObject(plumbing.AnyObject, hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("repository: object %s: %v", hash, err)
+		}
+		switch obj.Type() {
+		case plumbing.TagObject:
+			tag, err := object.DecodeTag(repository.Storer, obj)
+			if err != nil {
+				return nil, fmt.Errorf("repository: tag %s: %v", hash, err)
+			}
+			queue = append(queue, tag.Target)
+		case plumbing.CommitObject:
+			commit, err := object.DecodeCommit(repository.Storer, obj)
+			if err != nil {
+				return nil, fmt.Errorf("repository: commit %s: %v", hash, err)
+			}
+			reachable[hash] = struct{}{}
+			queue = append(queue, commit.ParentHashes...)
+		}
+	}
+	return reachable, nil
+}
+
+// isShallowParent reports whether the hash is a parent of a commit at the shallow boundary,
+// which is not stored in the repository until its history is deepened.
+func (node *CommitsNode) isShallowParent(hash string) bool {
+	if !plumbing.IsHash(hash) {
+		return false
+	}
+	parents, err := node.options.shallowParents(node.repository)
+	if err != nil {
+		return false
+	}
+	_, ok := parents[plumbing.NewHash(hash)]
+	return ok
+}
+
+// shallowParents returns hashes of the parents of the commits at the shallow boundary,
+// cached until the references change, since the boundary moves with a fetch only.
+// Nodes created without the root node options read the parents on every call.
+func (options *Options) shallowParents(repository *git.Repository) (map[plumbing.Hash]struct{}, error) {
+	if options == nil || options.shallow == nil {
+		return shallowParents(repository)
+	}
+	return options.shallow.get(options.referenceIndex(repository), func() (map[plumbing.Hash]struct{}, error) {
+		return shallowParents(repository)
+	})
+}
+
+func shallowParents(repository *git.Repository) (map[plumbing.Hash]struct{}, error) {
+	shallow, err := repository.Storer.Shallow()
+	if err != nil {
+		return nil, fmt.Errorf("repository: shallow: %v", err)
+	}
+	parents := make(map[plumbing.Hash]struct{})
+	for _, shallowHash := range shallow {
+		commit, err := repository.CommitObject(shallowHash)
+		if err != nil {
+			continue
+		}
+		for _, parentHash := range commit.ParentHashes {
+			parents[parentHash] = struct{}{}
+		}
+	}
+	return parents, nil
+}
+
+// Getattr returns the directory attributes.
+// Commits are not counted, since listing them is expensive for large repositories.
+func (node *CommitsNode) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	setUncountedDirAttr(&out.Attr)
+	return 0
 }
@@ -0,0 +1,119 @@
+package nodes
+
+import (
+	"context"
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnreachableCommits(t *testing.T) {
+	var orphan plumbing.Hash
+	prepare := func(repoPath string) error {
+		blob, err := runGitInput(repoPath, "lost content\n", "hash-object", "-w", "--stdin")
+		if err != nil {
+			return err
+		}
+		tree, err := runGitInput(repoPath, "100644 blob "+blob+"\ttestfile1\n", "mktree")
+		if err != nil {
+			return err
+		}
+		hash, err := runGit(repoPath, "commit-tree", "-p", commits[3], "-m", "lost after rebase", tree)
+		orphan = plumbing.NewHash(hash)
+		return err
+	}
+
+	t.Run("all commits", func(t *testing.T) {
+		mountPoint := testdata.InitializePrepared(t, NewRootNode, prepare)
+
+		entries, err := os.ReadDir(filepath.Join(mountPoint, "commits"))
+		require.NoError(t, err)
+		require.ElementsMatch(t, append([]string{orphan.String()}, commits...), dirEntriesNames(entries))
+
+		entries, err = os.ReadDir(filepath.Join(mountPoint, "unreachable"))
+		require.NoError(t, err)
+		require.Equal(t, []string{orphan.String()}, dirEntriesNames(entries))
+
+		content, err := os.ReadFile(filepath.Join(mountPoint, "unreachable", orphan.String(), "testfile1"))
+		require.NoError(t, err)
+		require.Equal(t, "lost content\n", string(content))
+
+		_, err = os.Stat(filepath.Join(mountPoint, "unreachable", commits[0]))
+		require.True(t, os.IsNotExist(err))
+		// Unreachable commits are looked up by their full hashes only.
+		_, err = os.Stat(filepath.Join(mountPoint, "unreachable", "HEAD"))
+		require.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(mountPoint, "unreachable", orphan.String()[:7]))
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("reachable commits only", func(t *testing.T) {
+		var repository *git.Repository
+		var root *RootNode
+		mountPoint := testdata.InitializePrepared(t, func(r *git.Repository) *RootNode {
+			repository = r
+			root = NewRootNodeWithOptions(r, Options{ReachableCommitsOnly: true, ReferencesWatched: true})
+			return root
+		}, prepare)
+
+		entries, err := os.ReadDir(filepath.Join(mountPoint, "commits"))
+		require.NoError(t, err)
+		require.ElementsMatch(t, commits, dirEntriesNames(entries))
+
+		_, err = os.Stat(filepath.Join(mountPoint, "commits", orphan.String()))
+		require.True(t, os.IsNotExist(err))
+		_, err = os.Stat(filepath.Join(mountPoint, "commits", "master"))
+		require.NoError(t, err)
+
+		// The reachable commits are computed again once the references change.
+		require.NoError(t, repository.Storer.SetReference(
+			plumbing.NewHashReference(plumbing.NewBranchReferenceName("rescued"), orphan),
+		))
+		root.InvalidateReferences(context.Background())
+		content, err := os.ReadFile(filepath.Join(mountPoint, "commits", orphan.String(), "testfile1"))
+		require.NoError(t, err)
+		require.Equal(t, "lost content\n", string(content))
+	})
+}
+
+func TestShallowBoundary(t *testing.T) {
+	var root *RootNode
+	var gitDir string
+	mountPoint := testdata.InitializePrepared(t, func(r *git.Repository) *RootNode {
+		root = NewRootNodeWithOptions(r, Options{ReachableCommitsOnly: true, FileModTimeFromHistory: true})
+		return root
+	}, func(repoPath string) error {
+		// The clone of depth 3 keeps commits[1] without its parent commits[0].
+		gitDir = filepath.Join(repoPath, git.GitDirName)
+		err := os.WriteFile(filepath.Join(gitDir, "shallow"), []byte(commits[1]+"\n"), 0644)
+		if err != nil {
+			return err
+		}
+		return os.Remove(filepath.Join(gitDir, "objects", commits[0][:2], commits[0][2:]))
+	})
+
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "commits"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, commits[1:], dirEntriesNames(entries))
+
+	_, err = os.Stat(filepath.Join(mountPoint, "commits", commits[0]))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	commitsNode := NewCommitsNode(root.repository, &root.options, ReachableCommits)
+	require.True(t, commitsNode.isShallowParent(commits[0]))
+	require.False(t, commitsNode.isShallowParent(commits[2]))
+
+	// The file added before the boundary is reported as modified by the boundary commit.
+	info, err := os.Stat(filepath.Join(mountPoint, "commits", commits[3], "testfile1"))
+	require.NoError(t, err)
+	require.Equal(t, int64(1680125522), info.ModTime().Unix())
+
+	// The boundary is read again once the references change, for example, after a fetch.
+	require.NoError(t, os.Remove(filepath.Join(gitDir, "shallow")))
+	require.True(t, commitsNode.isShallowParent(commits[0]))
+	root.InvalidateReferences(context.Background())
+	require.False(t, commitsNode.isShallowParent(commits[0]))
+}
@@ -9,27 +9,45 @@ import (
 	"io"
 	"log/slog"
 	"syscall"
+	"time"
 )
 
 var (
-	_ fs.InodeEmbedder = (*FileNode)(nil)
-	_ fs.NodeOpener    = (*FileNode)(nil)
-	_ fs.NodeGetattrer = (*FileNode)(nil)
+	_ fs.InodeEmbedder   = (*FileNode)(nil)
+	_ fs.NodeOpener      = (*FileNode)(nil)
+	_ fs.NodeGetattrer   = (*FileNode)(nil)
+	_ fs.NodeOnForgetter = (*FileNode)(nil)
+
+	_ fs.NodeGetxattrer  = (*FileNode)(nil)
+	_ fs.NodeListxattrer = (*FileNode)(nil)
 )
 
 // FileNode is a file node.
 type FileNode struct {
 	fs.Inode
-	file   *object.File
-	commit *object.Commit
+	file     *object.File
+	revision string
+	commit   *object.Commit
+	modTime  time.Time
+	// inodes is the table the inode number of the node is allocated from.
+	inodes *inodeTable
 }
 
 // NewFileNode creates a new file node.
-func NewFileNode(file *object.File, commit *object.Commit) *FileNode {
-	return &FileNode{file: file, commit: commit}
+// The revision and the commit are the ones the file belongs to,
+// they are empty for files not belonging to any commit, for example, staged or tagged files.
+// The modTime is reported as the file modification time.
+func NewFileNode(file *object.File, revision string, commit *object.Commit, modTime time.Time) *FileNode {
+	return &FileNode{file: file, revision: revision, commit: commit, modTime: modTime}
+}
+
+// OnForget releases the inode number of the node.
+func (node *FileNode) OnForget() {
+	node.inodes.release(node.StableAttr().Ino)
 }
 
 // Open opens the file.
+// Blobs never change, so the kernel is asked to keep the page cache of the file between opens.
 func (node *FileNode) Open(_ context.Context, _ uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
 	logger := slog.Default().
 		With(slog.String("fileName", node.file.Name)).
@@ -44,7 +62,7 @@ func (node *FileNode) Open(_ context.Context, _ uint32) (fh fs.FileHandle, fuseF
 	// So, we can use io.ReaderAt directly.
 	if readerAt, ok := reader.(io.ReaderAt); ok {
 		logger.Info("File opened")
-		return NewFileHandler(node.file, readerAt), 0, 0
+		return NewFileHandler(node.file, readerAt), fuse.FOPEN_KEEP_CACHE, 0
 	}
 
 	buf, err := io.ReadAll(reader)
@@ -54,18 +72,39 @@ func (node *FileNode) Open(_ context.Context, _ uint32) (fh fs.FileHandle, fuseF
 	}
 	logger.Info("File opened")
 
-	return NewFileHandler(node.file, bytes.NewReader(buf)), 0, 0
+	return NewFileHandler(node.file, bytes.NewReader(buf)), fuse.FOPEN_KEEP_CACHE, 0
 }
 
 // Getattr gets the file attributes.
 func (node *FileNode) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	out.Size = uint64(node.file.Size)
 	out.Mode = uint32(node.file.Mode)
-	out.Mtime = uint64(node.commit.Committer.When.Unix())
+	out.SetTimes(&node.modTime, &node.modTime, &node.modTime)
+	out.SetTimeout(immutableCacheTimeout)
 	slog.Default().Debug("Got file attrs", slog.String("name", node.file.Name))
 	return 0
 }
 
+// Getxattr returns the extended attribute value.
+func (node *FileNode) Getxattr(_ context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	return getxattr(node.xattrs(), attr, dest)
+}
+
+// Listxattr returns the extended attribute names.
+func (node *FileNode) Listxattr(_ context.Context, dest []byte) (uint32, syscall.Errno) {
+	return listxattr(node.xattrs(), dest)
+}
+
+// xattrs returns the extended attributes of the file: the blob hash, the mode,
+// the commit hash and the revision the file belongs to.
+func (node *FileNode) xattrs() []xattr {
+	attrs := []xattr{
+		{name: blobXattrName, value: node.file.Hash.String()},
+		{name: modeXattrName, value: formatMode(node.file.Mode)},
+	}
+	return appendCommitXattrs(attrs, node.revision, node.commit)
+}
+
 var (
 	_ fs.FileReader = (*FileHandler)(nil)
 )
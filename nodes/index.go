@@ -0,0 +1,170 @@
+package nodes
+
+import (
+	"context"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"log/slog"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+var (
+	_ fs.InodeEmbedder = (*IndexNode)(nil)
+	_ fs.NodeReaddirer = (*IndexNode)(nil)
+	_ fs.NodeLookuper  = (*IndexNode)(nil)
+	_ fs.NodeGetattrer = (*IndexNode)(nil)
+)
+
+// indexPathSeparator is the separator of paths stored in the index.
+// Git always uses forward slash regardless of the platform.
+const indexPathSeparator = "/"
+
+// IndexNode is a filesystem node that represents a directory of the staging area (.git/index).
+// Index stores a flat list of staged file paths, so directories are derived from the paths prefixes.
+// For example, if the index contains "foo/bar" and "foo/baz" entries, then there will be
+// "foo" directory with "bar" and "baz" files inside.
+// The index is read on every lookup and readdir, so the node always reflects the current staging area.
+type IndexNode struct {
+	fs.Inode
+	repository *git.Repository
+	options    *Options
+	pathPrefix string
+}
+
+// NewIndexNode creates a new IndexNode.
+// The pathPrefix is the path of the directory inside the index with trailing separator,
+// it is empty for the root of the index.
+func NewIndexNode(repository *git.Repository, options *Options, pathPrefix string) *IndexNode {
+	return &IndexNode{repository: repository, options: options, pathPrefix: pathPrefix}
+}
+
+// Lookup returns a file node if the name is a staged file,
+// or a nested IndexNode if the name is a directory of staged files.
+// It returns ENOENT if the name is not found.
+func (node *IndexNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().
+		With(slog.String("lookupIndexEntryName", name)).
+		With(slog.String("pathPrefix", node.pathPrefix))
+	entries, err := node.entries()
+	if err != nil {
+		logger.Error("Error lookup index entry", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+
+	path := node.pathPrefix + name
+	dirPrefix := path + indexPathSeparator
+	isDir := false
+	for _, entry := range entries {
+		if entry.Name == path {
+			blob, err := object.GetBlob(node.repository.Storer, entry.Hash)
+			if err != nil {
+				logger.Error("Error lookup index entry blob", slog.String("error", err.Error()))
+				return nil, syscall.ENOENT
+			}
+			logger.Info("Index file found")
+			fileNode := NewFileNode(object.NewFile(path, entry.Mode, blob), "", nil, entry.ModifiedAt)
+			return newEntryInode(ctx, &node.Inode, out, fileNode, node.options.fileStableAttr(fileNode)), 0
+		}
+		if strings.HasPrefix(entry.Name, dirPrefix) {
+			isDir = true
+		}
+	}
+	if !isDir {
+		logger.Warn("Index entry not found")
+		return nil, syscall.ENOENT
+	}
+	logger.Info("Index directory found")
+
+	return newEntryInode(
+		ctx,
+		&node.Inode,
+		out,
+		NewIndexNode(node.repository, node.options, dirPrefix),
+		node.options.pathStableAttr(&node.Inode, name),
+	), 0
+}
+
+// Readdir returns the files and directories of the staging area located under the node path.
+func (node *IndexNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	entries, err := node.entries()
+	if err != nil {
+		slog.Default().Error("Error read index", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+
+	modes := make(map[string]uint32)
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name, node.pathPrefix) {
+			continue
+		}
+		segments := strings.SplitN(strings.TrimPrefix(entry.Name, node.pathPrefix), indexPathSeparator, 2)
+		if len(segments) > 1 {
+			modes[segments[0]] = syscall.S_IFDIR
+			continue
+		}
+		modes[segments[0]] = syscall.S_IFREG
+	}
+
+	dirEntries := make([]fuse.DirEntry, 0, len(modes))
+	for name, mode := range modes {
+		dirEntries = append(dirEntries, fuse.DirEntry{Name: name, Mode: mode})
+	}
+	sort.Slice(dirEntries, func(i, j int) bool { return dirEntries[i].Name < dirEntries[j].Name })
+	slog.Default().Info("Dir of repository index has been read", slog.String("pathPrefix", node.pathPrefix))
+
+	return fs.NewListDirStream(dirEntries), 0
+}
+
+// entries returns the staged file entries.
+// Submodules are skipped because their content is not stored in the repository.
+// Conflicted paths are represented by "ours" stage of the merge, or by "theirs" stage
+// if the path is deleted by ours, or by the common ancestor stage if it is deleted by both.
+func (node *IndexNode) entries() ([]*index.Entry, error) {
+	idx, err := node.repository.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*index.Entry, 0, len(idx.Entries))
+	positions := make(map[string]int, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		if !entry.Mode.IsFile() {
+			continue
+		}
+		position, ok := positions[entry.Name]
+		if !ok {
+			positions[entry.Name] = len(entries)
+			entries = append(entries, entry)
+			continue
+		}
+		if stagePriority(entry.Stage) < stagePriority(entries[position].Stage) {
+			entries[position] = entry
+		}
+	}
+	return entries, nil
+}
+
+// stagePriority returns the priority of the stage representing the path, the lower is the preferred one.
+func stagePriority(stage index.Stage) int {
+	switch stage {
+	case 0:
+		// go-git declares index.Merged as 1, the same as index.AncestorMode,
+		// but the merged entries are read with the stage 0.
+		return 0
+	case index.OurMode:
+		return 1
+	case index.TheirMode:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Getattr returns the directory attributes.
+func (node *IndexNode) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return setReaddirAttr(ctx, node, &out.Attr)
+}
@@ -0,0 +1,50 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIndexConflicts(t *testing.T) {
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(repoPath string) error {
+		// testfile1 is changed by both branches, testfile2 is deleted by ours and changed by theirs.
+		for _, step := range []struct {
+			files map[string]string
+			args  []string
+		}{
+			{args: []string{"checkout", "-q", "-b", "theirs"}},
+			{files: map[string]string{"testfile1": "theirs\n", "testfile2": "theirs\n"}, args: []string{"commit", "-q", "-a", "-m", "theirs"}},
+			{args: []string{"checkout", "-q", "nested/dir/test"}},
+			{args: []string{"rm", "-q", "testfile2"}},
+			{files: map[string]string{"testfile1": "ours\n"}, args: []string{"commit", "-q", "-a", "-m", "ours"}},
+		} {
+			for name, content := range step.files {
+				if err := os.WriteFile(filepath.Join(repoPath, name), []byte(content), 0644); err != nil {
+					return err
+				}
+			}
+			if _, err := runGit(repoPath, step.args...); err != nil {
+				return err
+			}
+		}
+		if _, err := runGit(repoPath, "merge", "-q", "theirs"); err == nil {
+			t.Fatal("merge is expected to conflict")
+		}
+		return nil
+	})
+
+	for path, content := range map[string]string{
+		"index/testfile1": "ours\n",
+		"index/testfile2": "theirs\n",
+	} {
+		actual, err := os.ReadFile(filepath.Join(mountPoint, path))
+		require.NoError(t, err, path)
+		require.Equal(t, content, string(actual), path)
+	}
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "index"))
+	require.NoError(t, err)
+	require.Contains(t, dirEntriesNames(entries), "testfile2")
+}
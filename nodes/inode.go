@@ -0,0 +1,164 @@
+package nodes
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"path"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+const (
+	// maxObjectIno limits the inode numbers derived from object ids,
+	// go-fuse assigns automatic inode numbers starting from 1<<63.
+	maxObjectIno = 1<<63 - 1
+	// minObjectIno skips zero and the root inode numbers.
+	minObjectIno = 2
+)
+
+// inodeTable allocates inode numbers derived from git object ids.
+// The inode number is a truncated digest of the object key, so it is the same across remounts.
+// The table remembers the keys of the numbers allocated to the nodes known by the kernel,
+// the numbers are released once the kernel forgets the nodes. If two different keys
+// are truncated to the same number while both are in use, the later one probes the numbers
+// of the key digested again with a counter, so the number of any key is the same regardless
+// of the order of lookups unless it collides with a key in use.
+type inodeTable struct {
+	mu   sync.Mutex
+	keys map[uint64][sha256.Size]byte
+}
+
+func newInodeTable() *inodeTable {
+	return &inodeTable{keys: make(map[uint64][sha256.Size]byte)}
+}
+
+// ino allocates the inode number of the key.
+// A nil table derives inode numbers without collision detection.
+func (table *inodeTable) ino(key string) uint64 {
+	if table == nil {
+		return probeIno(key, 0)
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	ino, allocated := table.probe(key)
+	if !allocated {
+		table.keys[ino] = sha256.Sum256([]byte(key))
+	}
+	return ino
+}
+
+// peek returns the inode number of the key without allocating it,
+// for example, for the directory entries which are not looked up yet.
+// It differs from the allocated number only if another key collides with it later.
+func (table *inodeTable) peek(key string) uint64 {
+	if table == nil {
+		return probeIno(key, 0)
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	ino, _ := table.probe(key)
+	return ino
+}
+
+// probe returns the first number of the key which is free or allocated to the key already.
+func (table *inodeTable) probe(key string) (uint64, bool) {
+	digest := sha256.Sum256([]byte(key))
+	for attempt := 0; ; attempt++ {
+		ino := probeIno(key, attempt)
+		known, ok := table.keys[ino]
+		if !ok {
+			return ino, false
+		}
+		if known == digest {
+			return ino, true
+		}
+	}
+}
+
+// release releases the inode number of the node forgotten by the kernel.
+func (table *inodeTable) release(ino uint64) {
+	if table == nil {
+		return
+	}
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	delete(table.keys, ino)
+}
+
+// probeIno returns the inode number of the key for the probe attempt.
+func probeIno(key string, attempt int) uint64 {
+	if attempt > 0 {
+		key += "\x00" + strconv.Itoa(attempt)
+	}
+	digest := sha256.Sum256([]byte(key))
+	ino := binary.BigEndian.Uint64(digest[:]) & maxObjectIno
+	return max(ino, minObjectIno)
+}
+
+// fileStableAttr returns the stable attributes of the file node.
+// Files share the inode number if they have the same blob and mode, so identical content seen
+// through different revisions, commits or the index is a hard link and is cached by the kernel once.
+// The attributes which depend on the view, the modification time and the commit attributes,
+// are the ones of the view the file has been looked up through first, until the kernel forgets it.
+// Files of different repositories mounted together don't share the inode, since the nodes
+// read the content from the storage of their repository. The keys of the other nodes are unique
+// to the repository, since they are derived from the path in the mount.
+// The node releases the inode number once the kernel forgets it.
+func (options *Options) fileStableAttr(node *FileNode) fs.StableAttr {
+	node.inodes = options.inodeTable()
+	return fs.StableAttr{Mode: syscall.S_IFREG, Ino: options.inodeTable().ino(options.fileInoKey(node))}
+}
+
+// treeStableAttr returns the stable attributes of the tree node looked up by the name in the parent.
+// Directories can't be hard-linked, so their inode numbers are derived from the tree id
+// together with the path of the directory in the mount.
+func (options *Options) treeStableAttr(parent *fs.Inode, name string, tree *object.Tree) fs.StableAttr {
+	return fs.StableAttr{Mode: syscall.S_IFDIR, Ino: options.inodeTable().ino(treeInoKey(tree.Hash, parent, name))}
+}
+
+// commitStableAttr returns the stable attributes of the commit tree node looked up by the name in the parent
+// through a reference which moves, for example, a branch. The inode number is derived from the commit id,
+// so the directory gets a new inode once the branch moves to another commit, even if the tree is the same.
+func (options *Options) commitStableAttr(parent *fs.Inode, name string, commit plumbing.Hash) fs.StableAttr {
+	return fs.StableAttr{Mode: syscall.S_IFDIR, Ino: options.inodeTable().ino(commitInoKey(commit, parent, name))}
+}
+
+// pathStableAttr returns the stable attributes of the directory not backed by a git object
+// looked up by the name in the parent, for example, the root directories or "feature" directory
+// of "feature/foo" branch. The inode number is derived from the path of the directory in the mount,
+// so the directory entries listed by the parent have the inode numbers of the looked up directories,
+// and the directory looked up again keeps the inode together with the kernel cache of its content.
+func (options *Options) pathStableAttr(parent *fs.Inode, name string) fs.StableAttr {
+	return fs.StableAttr{Mode: syscall.S_IFDIR, Ino: options.inodeTable().ino(pathInoKey(parent, name))}
+}
+
+func (options *Options) inodeTable() *inodeTable {
+	if options == nil {
+		return nil
+	}
+	return options.inodes
+}
+
+func (options *Options) fileInoKey(node *FileNode) string {
+	repositoryName := ""
+	if options != nil {
+		repositoryName = options.repositoryName
+	}
+	return "blob " + node.file.Hash.String() + " " + strconv.FormatUint(uint64(node.file.Mode), 8) + " " + repositoryName
+}
+
+func pathInoKey(parent *fs.Inode, name string) string {
+	return "path " + path.Join(parent.Path(nil), name)
+}
+
+func commitInoKey(hash plumbing.Hash, parent *fs.Inode, name string) string {
+	return "commit " + hash.String() + " " + path.Join(parent.Path(nil), name)
+}
+
+func treeInoKey(hash plumbing.Hash, parent *fs.Inode, name string) string {
+	return "tree " + hash.String() + " " + path.Join(parent.Path(nil), name)
+}
@@ -0,0 +1,57 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestStableInodes(t *testing.T) {
+	stat := func(t *testing.T, path string) *syscall.Stat_t {
+		info, err := os.Stat(path)
+		require.NoError(t, err, path)
+		return info.Sys().(*syscall.Stat_t)
+	}
+	mountPoint := testdata.Initialize(t, NewRootNode)
+
+	branchFile := stat(t, filepath.Join(mountPoint, "branches", "nested", "dir", "test", "testfile1"))
+	commitFile := stat(t, filepath.Join(mountPoint, "commits", commits[0], "testfile1"))
+	indexFile := stat(t, filepath.Join(mountPoint, "index", "testfile1"))
+	require.Equal(t, branchFile.Ino, commitFile.Ino)
+	require.Equal(t, branchFile.Ino, indexFile.Ino)
+	require.NotEqual(t, commitFile.Ino, stat(t, filepath.Join(mountPoint, "commits", commits[3], "testfile2")).Ino)
+
+	branchDir := stat(t, filepath.Join(mountPoint, "branches", "nested", "dir", "test", "testdir"))
+	commitDir := stat(t, filepath.Join(mountPoint, "commits", commits[3], "testdir"))
+	require.NotEqual(t, branchDir.Ino, commitDir.Ino)
+
+	remountPoint := testdata.Initialize(t, NewRootNode)
+	require.Equal(t, commitFile.Ino, stat(t, filepath.Join(remountPoint, "commits", commits[0], "testfile1")).Ino)
+	require.Equal(t, commitDir.Ino, stat(t, filepath.Join(remountPoint, "commits", commits[3], "testdir")).Ino)
+}
+
+func TestInodeTableCollision(t *testing.T) {
+	table := newInodeTable()
+	ino := table.ino("first")
+	require.Equal(t, ino, table.ino("first"))
+	require.GreaterOrEqual(t, ino, uint64(minObjectIno))
+	require.LessOrEqual(t, ino, uint64(maxObjectIno))
+
+	require.Equal(t, ino, table.peek("first"))
+
+	// Pretend another key in use is truncated to the same inode number.
+	collided := newInodeTable()
+	collided.keys[ino] = [32]byte{1}
+	probed := collided.peek("first")
+	require.NotEqual(t, ino, probed)
+	require.Equal(t, probeIno("first", 1), probed)
+	require.Equal(t, probed, collided.ino("first"))
+	require.Equal(t, probed, collided.ino("first"))
+	// The number is allocated to the key once the other one and the probed one are released.
+	collided.release(ino)
+	collided.release(probed)
+	require.Equal(t, ino, collided.ino("first"))
+}
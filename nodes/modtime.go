@@ -0,0 +1,136 @@
+package nodes
+
+import (
+	"container/list"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"sync"
+	"time"
+)
+
+// modTimeCacheSize is the number of the modification times kept by the cache.
+const modTimeCacheSize = 1 << 16
+
+// modTimeCache caches the modification times of files computed from the history.
+// The times are keyed by the commit and the file path, since the history of a commit never changes.
+// The least recently used times are evicted once the cache holds the size of them.
+type modTimeCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	times map[modTimeKey]*list.Element
+}
+
+type modTimeKey struct {
+	commit plumbing.Hash
+	path   string
+}
+
+type modTimeEntry struct {
+	key     modTimeKey
+	modTime time.Time
+}
+
+func newModTimeCache(size int) *modTimeCache {
+	return &modTimeCache{size: size, order: list.New(), times: make(map[modTimeKey]*list.Element)}
+}
+
+// lastModified returns the committer time of the most recent commit that modified the file
+// at the path, starting from the commit.
+// It follows the history the same way as `git log -1 -- <path>` does: the first parent
+// having the same file is followed, and the commit with no such parent is the one that modified the file.
+// Only the time of the starting commit and of the commit that modified the file are cached,
+// so a long walk doesn't evict the other times, and the later walks through the same history
+// stop at the commit that modified the file.
+// It returns false if the file at the path of the commit has different blob or mode,
+// for example, for the stashed index files which do not belong to the stash commit tree.
+// A nil cache computes the time without caching.
+func (cache *modTimeCache) lastModified(
+	repository *git.Repository,
+	commit *object.Commit,
+	path string,
+	file *object.File,
+) (time.Time, bool) {
+	if !hasFile(commit, path, file) {
+		return time.Time{}, false
+	}
+	start := commit.Hash
+	for {
+		if modTime, ok := cache.get(commit.Hash, path); ok {
+			cache.put([]plumbing.Hash{start}, path, modTime)
+			return modTime, true
+		}
+		next := sameFileParent(repository, commit, path, file)
+		if next == nil {
+			cache.put([]plumbing.Hash{start, commit.Hash}, path, commit.Committer.When)
+			return commit.Committer.When, true
+		}
+		commit = next
+	}
+}
+
+func (cache *modTimeCache) get(commit plumbing.Hash, path string) (time.Time, bool) {
+	if cache == nil {
+		return time.Time{}, false
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	element, ok := cache.times[modTimeKey{commit: commit, path: path}]
+	if !ok {
+		return time.Time{}, false
+	}
+	cache.order.MoveToFront(element)
+	return element.Value.(*modTimeEntry).modTime, true
+}
+
+func (cache *modTimeCache) put(commits []plumbing.Hash, path string, modTime time.Time) {
+	if cache == nil {
+		return
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for _, commit := range commits {
+		key := modTimeKey{commit: commit, path: path}
+		if element, ok := cache.times[key]; ok {
+			element.Value.(*modTimeEntry).modTime = modTime
+			cache.order.MoveToFront(element)
+			continue
+		}
+		cache.times[key] = cache.order.PushFront(&modTimeEntry{key: key, modTime: modTime})
+		if cache.order.Len() > cache.size {
+			oldest := cache.order.Back()
+			cache.order.Remove(oldest)
+			delete(cache.times, oldest.Value.(*modTimeEntry).key)
+		}
+	}
+}
+
+// sameFileParent returns the first parent of the commit having the same file at the path,
+// or nil if the commit modified the file. Missing parents, for example, beyond the shallow clone
+// boundary, are treated as not having the file.
+func sameFileParent(repository *git.Repository, commit *object.Commit, path string, file *object.File) *object.Commit {
+	for _, parentHash := range commit.ParentHashes {
+		parent, err := repository.CommitObject(parentHash)
+		if err != nil {
+			continue
+		}
+		if hasFile(parent, path, file) {
+			return parent
+		}
+	}
+	return nil
+}
+
+// hasFile reports whether the commit tree has the file with the same blob and mode at the path.
+func hasFile(commit *object.Commit, path string, file *object.File) bool {
+	tree, err := commit.Tree()
+	if err != nil {
+		return false
+	}
+	entry, err := tree.FindEntry(path)
+	if err != nil {
+		return false
+	}
+	return entry.Hash == file.Hash && entry.Mode == file.Mode
+}
@@ -0,0 +1,75 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileModTimeFromHistory(t *testing.T) {
+	modTimes := map[string]int64{
+		"testfile1":         1680125446,
+		"testfile2":         1680125522,
+		"testfile3":         1680134187,
+		"testdir/testfile4": 1680698291,
+	}
+
+	t.Run("commit time", func(t *testing.T) {
+		mountPoint := testdata.Initialize(t, NewRootNode)
+		for path := range modTimes {
+			info, err := os.Stat(filepath.Join(mountPoint, "commits", commits[3], path))
+			require.NoError(t, err, path)
+			require.Equal(t, int64(1680698291), info.ModTime().Unix(), path)
+		}
+		// The same file seen through another commit has the time of that commit.
+		info, err := os.Stat(filepath.Join(mountPoint, "commits", commits[0], "testfile1"))
+		require.NoError(t, err)
+		require.Equal(t, int64(1680125446), info.ModTime().Unix())
+	})
+
+	t.Run("history time", func(t *testing.T) {
+		var root *RootNode
+		mountPoint := testdata.Initialize(t, func(repository *git.Repository) *RootNode {
+			root = NewRootNodeWithOptions(repository, Options{FileModTimeFromHistory: true})
+			return root
+		})
+		for path, modTime := range modTimes {
+			info, err := os.Stat(filepath.Join(mountPoint, "commits", commits[3], path))
+			require.NoError(t, err, path)
+			require.Equal(t, modTime, info.ModTime().Unix(), path)
+		}
+		// Only the starting commit and the commit that modified the file are cached.
+		for i, cached := range []bool{true, false, false, true} {
+			_, ok := root.options.modTimes.get(plumbing.NewHash(commits[3-i]), "testfile1")
+			require.Equal(t, cached, ok, commits[3-i])
+		}
+
+		info, err := os.Stat(filepath.Join(mountPoint, "commits", commits[3], "testdir"))
+		require.NoError(t, err)
+		require.Equal(t, int64(1680698291), info.ModTime().Unix())
+	})
+}
+
+func TestModTimeCacheEviction(t *testing.T) {
+	cache := newModTimeCache(2)
+	first, second, third := plumbing.NewHash("01"), plumbing.NewHash("02"), plumbing.NewHash("03")
+	cache.put([]plumbing.Hash{first, second}, "file", time.Unix(1, 0))
+	_, ok := cache.get(first, "file")
+	require.True(t, ok)
+
+	// The second time is the least recently used one.
+	cache.put([]plumbing.Hash{third}, "file", time.Unix(3, 0))
+	_, ok = cache.get(second, "file")
+	require.False(t, ok)
+	modTime, ok := cache.get(first, "file")
+	require.True(t, ok)
+	require.Equal(t, time.Unix(1, 0), modTime)
+	modTime, ok = cache.get(third, "file")
+	require.True(t, ok)
+	require.Equal(t, time.Unix(3, 0), modTime)
+}
@@ -0,0 +1,224 @@
+package nodes
+
+import (
+	"context"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/usage"
+	"github.com/go-git/go-git/v5"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+var (
+	_ fs.InodeEmbedder = (*MultiRootNode)(nil)
+	_ fs.NodeOnAdder   = (*MultiRootNode)(nil)
+	_ fs.NodeLookuper  = (*MultiRootNode)(nil)
+	_ fs.NodeGetattrer = (*MultiRootNode)(nil)
+	_ fs.NodeStatfser  = (*MultiRootNode)(nil)
+	_ fs.NodeReaddirer = (*MultiRootNode)(nil)
+)
+
+// MultiRootNode is the root node of the filesystem mounting several repositories,
+// each repository is a subdirectory with its own RootNode.
+// Repositories can be added and removed while the filesystem is mounted.
+type MultiRootNode struct {
+	fs.Inode
+	// inodes is shared by the repositories, so the inode numbers colliding across them are detected.
+	// The keys of the inode numbers are unique to the repository, so the nodes are never shared by them.
+	inodes *inodeTable
+
+	mu      sync.Mutex
+	mounted bool
+	roots   map[string]*RootNode
+}
+
+// NewMultiRootNode creates a new MultiRootNode without repositories.
+func NewMultiRootNode() *MultiRootNode {
+	return &MultiRootNode{inodes: newInodeTable(), roots: make(map[string]*RootNode)}
+}
+
+// Add adds the repository as the subdirectory with the name and returns the root node of it.
+// It returns an error if the name is taken by another repository or is not a valid file name.
+func (node *MultiRootNode) Add(
+	ctx context.Context,
+	name string,
+	repository *git.Repository,
+	options Options,
+) (*RootNode, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00") {
+		return nil, fmt.Errorf("invalid repository name %q", name)
+	}
+	root := NewRootNodeWithOptions(repository, options)
+	root.options.inodes = node.inodes
+	root.options.repositoryName = name
+
+	node.mu.Lock()
+	if _, ok := node.roots[name]; ok {
+		node.mu.Unlock()
+		return nil, fmt.Errorf("repository %s: %w", name, os.ErrExist)
+	}
+	node.roots[name] = root
+	mounted := node.mounted
+	if mounted {
+		node.addChild(ctx, name, root)
+	}
+	node.mu.Unlock()
+
+	if mounted {
+		// The name might be cached by the kernel as missing.
+		node.invalidate(name)
+	}
+	return root, nil
+}
+
+// Remove removes the repository subdirectory with the name.
+// The release function is called once the kernel forgets the subdirectory and the nodes in it,
+// since the files opened before the removal are still read, so the repository storage is closed then.
+// It is called right away if the filesystem is not mounted.
+func (node *MultiRootNode) Remove(_ context.Context, name string, release func()) error {
+	node.mu.Lock()
+	root, ok := node.roots[name]
+	if !ok {
+		node.mu.Unlock()
+		return fmt.Errorf("repository %s: %w", name, os.ErrNotExist)
+	}
+	delete(node.roots, name)
+	mounted := node.mounted
+	if mounted {
+		root.release = release
+		root.ForgetPersistent()
+		node.RmChild(name)
+	}
+	node.mu.Unlock()
+
+	if !mounted {
+		release()
+		return nil
+	}
+	node.invalidate(name)
+	invalidateTree(&root.Inode)
+	return nil
+}
+
+// invalidateTree drops the kernel cache of the entries in the directory of the removed repository,
+// so the kernel forgets the nodes once they are not in use anymore, instead of keeping them cached.
+func invalidateTree(parent *fs.Inode) {
+	for name, child := range parent.Children() {
+		invalidateTree(child)
+		if errno := parent.NotifyEntry(name); errno != 0 && errno != syscall.ENOENT {
+			slog.Default().Warn("Error invalidate entry", slog.String("name", name), slog.String("error", errno.Error()))
+		}
+	}
+}
+
+// Names returns the sorted names of the repositories.
+func (node *MultiRootNode) Names() []string {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	names := make([]string, 0, len(node.roots))
+	for name := range node.roots {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// OnAdd adds the subdirectories of the repositories added before the filesystem is mounted.
+func (node *MultiRootNode) OnAdd(ctx context.Context) {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	node.mounted = true
+	for name, root := range node.roots {
+		node.addChild(ctx, name, root)
+	}
+}
+
+// addChild adds the persistent inode of the repository root, so it is kept while the repository is mounted.
+// The inode number is allocated automatically, so the repository added again under the same name
+// does not get the inode of the removed one, which the kernel might still reference.
+func (node *MultiRootNode) addChild(ctx context.Context, name string, root *RootNode) {
+	child := node.NewPersistentInode(ctx, root, fs.StableAttr{Mode: syscall.S_IFDIR})
+	node.AddChild(name, child, false)
+}
+
+// invalidate drops the kernel cache of the repository entry and of the directory attributes,
+// since the number of subdirectories changes.
+func (node *MultiRootNode) invalidate(name string) {
+	logger := slog.Default().With(slog.String("repository", name))
+	if errno := node.NotifyEntry(name); errno != 0 && errno != syscall.ENOENT {
+		logger.Warn("Error invalidate repository entry", slog.String("error", errno.Error()))
+	}
+	if errno := node.NotifyContent(0, 0); errno != 0 {
+		logger.Warn("Error invalidate repositories dir", slog.String("error", errno.Error()))
+	}
+}
+
+// Lookup returns the root inode of the repository with the given name.
+// It returns ENOENT if the repository is not found.
+func (node *MultiRootNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	node.mu.Lock()
+	root, ok := node.roots[name]
+	node.mu.Unlock()
+	if !ok {
+		return nil, syscall.ENOENT
+	}
+	var attrOut fuse.AttrOut
+	if errno := root.Getattr(ctx, nil, &attrOut); errno == 0 {
+		out.Attr = attrOut.Attr
+	}
+	return root.EmbeddedInode(), 0
+}
+
+// Readdir returns the list of repositories.
+func (node *MultiRootNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	names := node.Names()
+	entries := make([]fuse.DirEntry, 0, len(names))
+	for _, name := range names {
+		entries = append(entries, fuse.DirEntry{Name: name, Mode: syscall.S_IFDIR})
+	}
+	return fs.NewListDirStream(entries), 0
+}
+
+// Getattr returns the directory attributes.
+// Each repository is a subdirectory.
+func (node *MultiRootNode) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	node.mu.Lock()
+	defer node.mu.Unlock()
+	setDirAttr(&out.Attr, len(node.roots))
+	return 0
+}
+
+// Statfs returns the filesystem statistics summed up over the repositories.
+// Statfs of a repository subdirectory returns the statistics of the repository only.
+// The usage of each repository is cached by its root node until its references change.
+func (node *MultiRootNode) Statfs(_ context.Context, out *fuse.StatfsOut) syscall.Errno {
+	node.mu.Lock()
+	roots := make(map[string]*RootNode, len(node.roots))
+	for name, root := range node.roots {
+		roots[name] = root
+	}
+	node.mu.Unlock()
+
+	var total usage.Usage
+	for name, root := range roots {
+		repositoryUsage, err := root.readUsage()
+		if err != nil {
+			slog.Default().Error(
+				"Error read repository usage",
+				slog.String("repository", name),
+				slog.String("error", err.Error()),
+			)
+			return syscall.EIO
+		}
+		total.Size += repositoryUsage.Size
+		total.Objects += repositoryUsage.Objects
+	}
+	setStatfs(out, total)
+	return 0
+}
@@ -0,0 +1,89 @@
+package nodes
+
+import (
+	"context"
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestMultiRoot(t *testing.T) {
+	var repository *git.Repository
+	var multiRoot *MultiRootNode
+	mountPoint := testdata.Initialize(t, func(r *git.Repository) *MultiRootNode {
+		repository = r
+		multiRoot = NewMultiRootNode()
+		_, err := multiRoot.Add(context.Background(), "first", r, Options{})
+		require.NoError(t, err)
+		_, err = multiRoot.Add(context.Background(), "second", r, Options{ReachableCommitsOnly: true})
+		require.NoError(t, err)
+		return multiRoot
+	})
+	ctx := context.Background()
+
+	requireRepositories := func(expected ...string) {
+		t.Helper()
+		entries, err := os.ReadDir(mountPoint)
+		require.NoError(t, err)
+		require.Equal(t, expected, dirEntriesNames(entries))
+		for _, name := range expected {
+			content, err := os.ReadFile(filepath.Join(mountPoint, name, "commits", commits[3], "testdir", "testfile4"))
+			require.NoError(t, err, name)
+			require.Equal(t, "content of testfile4\n", string(content), name)
+		}
+	}
+	requireRepositories("first", "second")
+
+	// The same file of different repositories is served by the node of its own repository.
+	firstFile, err := os.Stat(filepath.Join(mountPoint, "first", "commits", commits[3], "testfile1"))
+	require.NoError(t, err)
+	secondFile, err := os.Stat(filepath.Join(mountPoint, "second", "commits", commits[3], "testfile1"))
+	require.NoError(t, err)
+	require.NotEqual(t, firstFile.Sys().(*syscall.Stat_t).Ino, secondFile.Sys().(*syscall.Stat_t).Ino)
+
+	_, err = multiRoot.Add(ctx, "first", repository, Options{})
+	require.ErrorIs(t, err, os.ErrExist)
+	_, err = multiRoot.Add(ctx, "../first", repository, Options{})
+	require.ErrorContains(t, err, "invalid repository name")
+
+	// The missing repository is cached as missing by the kernel before it is added.
+	_, err = os.Stat(filepath.Join(mountPoint, "third"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = multiRoot.Add(ctx, "third", repository, Options{})
+	require.NoError(t, err)
+	requireRepositories("first", "second", "third")
+
+	// The repository is released once the kernel forgets its nodes, the file opened before is still read.
+	file, err := os.Open(filepath.Join(mountPoint, "first", "commits", commits[3], "testdir", "testfile4"))
+	require.NoError(t, err)
+	var released atomic.Bool
+	require.NoError(t, multiRoot.Remove(ctx, "first", func() { released.Store(true) }))
+	require.ErrorIs(t, multiRoot.Remove(ctx, "first", func() {}), os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(mountPoint, "first", "branches"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	requireRepositories("second", "third")
+	require.False(t, released.Load())
+	content, err := io.ReadAll(file)
+	require.NoError(t, err)
+	require.Equal(t, "content of testfile4\n", string(content))
+	require.NoError(t, file.Close())
+	require.Eventually(t, released.Load, 5*time.Second, 10*time.Millisecond)
+
+	_, err = multiRoot.Add(ctx, "first", repository, Options{})
+	require.NoError(t, err)
+	requireRepositories("first", "second", "third")
+
+	var total, single syscall.Statfs_t
+	require.NoError(t, syscall.Statfs(mountPoint, &total))
+	require.NoError(t, syscall.Statfs(filepath.Join(mountPoint, "first"), &single))
+	// The test repository keeps 13 loose objects.
+	require.Equal(t, uint64(13), single.Files)
+	require.Equal(t, 3*single.Files, total.Files)
+}
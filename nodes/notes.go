@@ -0,0 +1,313 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	_ fs.InodeEmbedder = (*NotesNode)(nil)
+	_ fs.NodeReaddirer = (*NotesNode)(nil)
+	_ fs.NodeLookuper  = (*NotesNode)(nil)
+	_ fs.NodeGetattrer = (*NotesNode)(nil)
+
+	_ fs.InodeEmbedder = (*NotesRefNode)(nil)
+	_ fs.NodeReaddirer = (*NotesRefNode)(nil)
+	_ fs.NodeLookuper  = (*NotesRefNode)(nil)
+	_ fs.NodeGetattrer = (*NotesRefNode)(nil)
+)
+
+const (
+	revisionNotesPrefix = "refs/notes/"
+	notesNameSeparator  = "/"
+	noteFileSuffix      = ".note"
+
+	// defaultNotesReferenceName is the notes reference `git notes` and `git log` use by default.
+	defaultNotesReferenceName = plumbing.ReferenceName(revisionNotesPrefix + "commits")
+)
+
+// NotesNode is a filesystem node that represents a list of notes references.
+// It is a directory that contains a directory for each notes reference.
+// If the notes reference name contains separator, it will be split into segments
+// and each segment will be a nested directory, the same way as branches are.
+type NotesNode struct {
+	fs.Inode
+	repository  *git.Repository
+	options     *Options
+	notesPrefix string
+}
+
+// NewNotesNode creates a new NotesNode.
+// The notesPrefix is empty for the root notes node.
+func NewNotesNode(repository *git.Repository, options *Options, notesPrefix string) *NotesNode {
+	return &NotesNode{repository: repository, options: options, notesPrefix: notesPrefix}
+}
+
+// Lookup returns a NotesRefNode if the name is a notes reference,
+// or a nested NotesNode if the name is a segment of notes references.
+// It returns ENOENT if the name is not found.
+func (node *NotesNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().
+		With(slog.String("lookupNotesName", name)).
+		With(slog.String("notesPrefix", node.notesPrefix))
+	refNames, err := node.refNames()
+	if err != nil {
+		logger.Error("Error lookup notes", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	revision := revisionNotesPrefix + node.notesPrefix + name
+	hasPrefix := false
+	for _, refName := range refNames {
+		if refName.String() == revision {
+			logger.Info("Notes reference found")
+			return newEntryInode(
+				ctx,
+				&node.Inode,
+				out,
+				NewNotesRefNode(node.repository, node.options, refName),
+				fs.StableAttr{Mode: syscall.S_IFDIR},
+			), 0
+		}
+		if strings.HasPrefix(refName.String(), revision+notesNameSeparator) {
+			hasPrefix = true
+		}
+	}
+	if !hasPrefix {
+		logger.Warn("Notes reference not found")
+		return nil, syscall.ENOENT
+	}
+	logger.Info("Notes segment found")
+
+	return newEntryInode(
+		ctx,
+		&node.Inode,
+		out,
+		NewNotesNode(node.repository, node.options, node.notesPrefix+name+notesNameSeparator),
+		fs.StableAttr{Mode: syscall.S_IFDIR},
+	), 0
+}
+
+// Readdir returns a list of notes references.
+func (node *NotesNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	refNames, err := node.refNames()
+	if err != nil {
+		slog.Default().Error("Error read notes", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	names := make(map[string]struct{})
+	for _, refName := range refNames {
+		name := strings.TrimPrefix(refName.String(), revisionNotesPrefix+node.notesPrefix)
+		names[strings.Split(name, notesNameSeparator)[0]] = struct{}{}
+	}
+	entries := make([]fuse.DirEntry, 0, len(names))
+	for name := range names {
+		entries = append(entries, fuse.DirEntry{Name: name, Mode: syscall.S_IFDIR})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	slog.Default().Info("Dir of repository notes has been read", slog.String("notesPrefix", node.notesPrefix))
+
+	return fs.NewListDirStream(entries), 0
+}
+
+// refNames returns names of the notes references starting with the node prefix.
+func (node *NotesNode) refNames() ([]plumbing.ReferenceName, error) {
+	refs, err := node.repository.References()
+	if err != nil {
+		return nil, err
+	}
+	var refNames []plumbing.ReferenceName
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if strings.HasPrefix(ref.Name().String(), revisionNotesPrefix+node.notesPrefix) {
+			refNames = append(refNames, ref.Name())
+		}
+		return nil
+	})
+	return refNames, err
+}
+
+// NotesRefNode is a filesystem node that represents notes of a notes reference.
+// It contains a file for each annotated object named by the object hash,
+// the content of the file is the note.
+type NotesRefNode struct {
+	fs.Inode
+	repository *git.Repository
+	options    *Options
+	refName    plumbing.ReferenceName
+}
+
+// NewNotesRefNode creates a new NotesRefNode.
+func NewNotesRefNode(repository *git.Repository, options *Options, refName plumbing.ReferenceName) *NotesRefNode {
+	return &NotesRefNode{repository: repository, options: options, refName: refName}
+}
+
+// Lookup returns the note file of the object by its hash.
+// It returns ENOENT if the object has no note.
+func (node *NotesRefNode) Lookup(ctx context.Context, hash string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().
+		With(slog.String("lookupNoteHash", hash)).
+		With(slog.String("refName", node.refName.String()))
+	if !plumbing.IsHash(hash) {
+		logger.Warn("Note not found")
+		return nil, syscall.ENOENT
+	}
+	noteNode, err := readNote(node.repository, node.refName, plumbing.NewHash(hash))
+	if err != nil {
+		logger.Warn("Error lookup note", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	logger.Info("Note found")
+
+	return newEntryInode(ctx, &node.Inode, out, noteNode, fs.StableAttr{Mode: syscall.S_IFREG}), 0
+}
+
+// Readdir returns a list of note files.
+func (node *NotesRefNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	notes, err := readNotes(node.repository, node.refName)
+	if err != nil {
+		slog.Default().Error("Error read notes", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	entries := make([]fuse.DirEntry, 0, len(notes.blobs))
+	for hash := range notes.blobs {
+		entries = append(entries, fuse.DirEntry{Name: hash.String(), Mode: syscall.S_IFREG})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	slog.Default().Info("Dir of notes has been read", slog.String("refName", node.refName.String()))
+
+	return fs.NewListDirStream(entries), 0
+}
+
+// notes holds notes of a notes reference.
+type notes struct {
+	// blobs maps hashes of the annotated objects to hashes of the note blobs.
+	blobs map[plumbing.Hash]plumbing.Hash
+}
+
+var errNoteNotFound = errors.New("note not found")
+
+// readNotes reads the notes tree of the notes reference.
+// Notes tree contains blobs named by annotated objects hashes, the hashes may be split
+// into nested directories ("fanout") in big notes trees, for example "d4/1f14efa3...".
+// If the notes reference doesn't exist, no notes are returned.
+func readNotes(repository *git.Repository, refName plumbing.ReferenceName) (*notes, error) {
+	result := &notes{blobs: make(map[plumbing.Hash]plumbing.Hash)}
+	_, tree, err := notesTree(repository, refName)
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		return result, nil
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("notes tree: %v", err)
+		}
+		hash := strings.ReplaceAll(name, notesNameSeparator, "")
+		if !entry.Mode.IsFile() || !plumbing.IsHash(hash) {
+			continue
+		}
+		result.blobs[plumbing.NewHash(hash)] = entry.Hash
+	}
+	return result, nil
+}
+
+// readNote returns a file node with the note of the object by the notes reference.
+// Only the fanout directories of the object hash are read, instead of the whole notes tree.
+// It returns errNoteNotFound if the object has no note.
+func readNote(repository *git.Repository, refName plumbing.ReferenceName, hash plumbing.Hash) (*TextFileNode, error) {
+	commit, tree, err := notesTree(repository, refName)
+	if err != nil {
+		return nil, err
+	}
+	if tree == nil {
+		return nil, errNoteNotFound
+	}
+	// Each fanout level takes two more hex digits of the hash, for example "d4/1f/14efa3...".
+	name := hash.String()
+	for {
+		entry, err := tree.FindEntry(name)
+		if err == nil && entry.Mode.IsFile() {
+			return newNoteNode(repository, entry.Hash, commit.Committer.When)
+		}
+		if len(name) <= 2 {
+			return nil, errNoteNotFound
+		}
+		entry, err = tree.FindEntry(name[:2])
+		if err != nil || entry.Mode.IsFile() {
+			return nil, errNoteNotFound
+		}
+		tree, err = repository.TreeObject(entry.Hash)
+		if err != nil {
+			return nil, fmt.Errorf("notes tree: %v", err)
+		}
+		name = name[2:]
+	}
+}
+
+// notesTree returns the commit of the notes reference and its tree.
+// If the notes reference doesn't exist, no commit and no tree are returned.
+func notesTree(repository *git.Repository, refName plumbing.ReferenceName) (*object.Commit, *object.Tree, error) {
+	ref, err := repository.Reference(refName, true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("repository: reference: %v", err)
+	}
+	commit, err := repository.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, nil, fmt.Errorf("repository: commit object: %v", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, nil, fmt.Errorf("commit tree: %v", err)
+	}
+	return commit, tree, nil
+}
+
+// newNoteNode returns a file node with the note blob content.
+func newNoteNode(repository *git.Repository, blobHash plumbing.Hash, modTime time.Time) (*TextFileNode, error) {
+	blob, err := repository.BlobObject(blobHash)
+	if err != nil {
+		return nil, fmt.Errorf("repository: blob object: %v", err)
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("blob reader: %v", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("blob read: %v", err)
+	}
+	return NewTextFileNode(data, modTime), nil
+}
+
+// Getattr returns the directory attributes.
+func (node *NotesNode) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return setReaddirAttr(ctx, node, &out.Attr)
+}
+
+// Getattr returns the directory attributes.
+func (node *NotesRefNode) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return setReaddirAttr(ctx, node, &out.Attr)
+}
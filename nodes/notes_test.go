@@ -0,0 +1,76 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNotes(t *testing.T) {
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(repoPath string) error {
+		for _, args := range [][]string{
+			{"notes", "add", "-m", "init note", commits[0]},
+			{"notes", "add", "-m", "build passed", commits[3]},
+		} {
+			if _, err := runGit(repoPath, args...); err != nil {
+				return err
+			}
+		}
+		// The notes of big notes trees are split into fanout directories named by the first digits of the hashes.
+		blob, err := runGitInput(repoPath, "build failed\n", "hash-object", "-w", "--stdin")
+		if err != nil {
+			return err
+		}
+		fanout, err := runGitInput(repoPath, "100644 blob "+blob+"\t"+commits[1][2:]+"\n", "mktree")
+		if err != nil {
+			return err
+		}
+		tree, err := runGitInput(repoPath, "040000 tree "+fanout+"\t"+commits[1][:2]+"\n", "mktree")
+		if err != nil {
+			return err
+		}
+		commit, err := runGit(repoPath, "commit-tree", "-m", "Notes added by CI", tree)
+		if err != nil {
+			return err
+		}
+		_, err = runGit(repoPath, "update-ref", revisionNotesPrefix+"ci/builds", commit)
+		return err
+	})
+
+	expected := map[string]string{
+		"notes/commits/" + commits[0]:     "init note\n",
+		"notes/commits/" + commits[3]:     "build passed\n",
+		"notes/ci/builds/" + commits[1]:   "build failed\n",
+		"commits/" + commits[0] + ".note": "init note\n",
+		"commits/" + commits[3] + ".note": "build passed\n",
+	}
+	for path, content := range expected {
+		actual, err := os.ReadFile(filepath.Join(mountPoint, path))
+		require.NoError(t, err, path)
+		require.Equal(t, content, string(actual), path)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "notes"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"ci", "commits"}, dirEntriesNames(entries))
+
+	entries, err = os.ReadDir(filepath.Join(mountPoint, "notes", "ci", "builds"))
+	require.NoError(t, err)
+	require.Equal(t, []string{commits[1]}, dirEntriesNames(entries))
+
+	entries, err = os.ReadDir(filepath.Join(mountPoint, "commits"))
+	require.NoError(t, err)
+	require.Subset(t, dirEntriesNames(entries), []string{commits[0] + ".note", commits[3] + ".note"})
+	require.NotContains(t, dirEntriesNames(entries), commits[1]+".note")
+
+	_, err = os.Stat(filepath.Join(mountPoint, "commits", commits[1]+".note"))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(mountPoint, "notes", "commits", plumbing.ZeroHash.String()))
+	require.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(mountPoint, "notes", "ci", "builds", commits[1][:2]+strings.Repeat("0", len(commits[1])-2)))
+	require.True(t, os.IsNotExist(err))
+}
@@ -6,33 +6,49 @@ import (
 	"github.com/dsxack/gitfs/internal/iter"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
 	"log/slog"
+	"path"
 	"syscall"
+	"time"
 )
 
 var (
-	_ fs.InodeEmbedder = (*ObjectTreeNode)(nil)
-	_ fs.NodeReaddirer = (*ObjectTreeNode)(nil)
-	_ fs.NodeGetattrer = (*ObjectTreeNode)(nil)
-	_ fs.NodeLookuper  = (*ObjectTreeNode)(nil)
+	_ fs.InodeEmbedder   = (*ObjectTreeNode)(nil)
+	_ fs.NodeReaddirer   = (*ObjectTreeNode)(nil)
+	_ fs.NodeGetattrer   = (*ObjectTreeNode)(nil)
+	_ fs.NodeLookuper    = (*ObjectTreeNode)(nil)
+	_ fs.NodeOnForgetter = (*ObjectTreeNode)(nil)
+
+	_ fs.NodeGetxattrer  = (*ObjectTreeNode)(nil)
+	_ fs.NodeListxattrer = (*ObjectTreeNode)(nil)
 )
 
+// signatureFileSuffix is the suffix of the signature verification file of commits and annotated tags.
+// For example, the verification result of "v1.0.0" tag is exposed as "v1.0.0.signature" file.
+const signatureFileSuffix = ".signature"
+
 // ObjectTreeNode is a node that represents a tree object in a git repository.
 // It is used to represent the content of commit.
+// It is also used to represent the tree pointed by a tag directly, in that case commit is nil.
 type ObjectTreeNode struct {
 	fs.Inode
 	repository *git.Repository
+	options    *Options
 	revision   string
 	commit     *object.Commit
 	tree       *object.Tree
+	// path is the path of the tree inside the commit tree, it is empty for the root tree.
+	path    string
+	modTime time.Time
 }
 
 // NewObjectTreeNodeByRevision creates a new ObjectTreeNode by a revision name.
 // The revision name can be a branch name, a tag name or a commit hash.
-func NewObjectTreeNodeByRevision(repository *git.Repository, revision string) (*ObjectTreeNode, error) {
+func NewObjectTreeNodeByRevision(repository *git.Repository, options *Options, revision string) (*ObjectTreeNode, error) {
 	h, err := repository.ResolveRevision(plumbing.Revision(revision))
 	if err != nil {
 		return nil, fmt.Errorf("repository: resolve revision: %v", err)
@@ -48,30 +64,54 @@ func NewObjectTreeNodeByRevision(repository *git.Repository, revision string) (*
 		return nil, fmt.Errorf("commit tree: %v", err)
 	}
 
-	return &ObjectTreeNode{
-		commit:     commit,
-		repository: repository,
-		revision:   revision,
-		tree:       tree,
-	}, nil
+	return NewObjectTreeNode(repository, options, revision, commit, tree), nil
 }
 
 // NewObjectTreeNode creates a new ObjectTreeNode.
 func NewObjectTreeNode(
 	repository *git.Repository,
+	options *Options,
 	revision string,
 	commit *object.Commit,
 	tree *object.Tree,
 ) *ObjectTreeNode {
 	return &ObjectTreeNode{
 		repository: repository,
+		options:    options,
 		revision:   revision,
 		commit:     commit,
 		tree:       tree,
+		modTime:    commit.Committer.When,
+	}
+}
+
+// NewObjectTreeNodeByTree creates a new ObjectTreeNode of the tree not belonging to any commit,
+// for example, the tree pointed by a tag.
+// The modTime is reported as the modification time of the tree files and directories.
+func NewObjectTreeNodeByTree(
+	repository *git.Repository,
+	options *Options,
+	revision string,
+	tree *object.Tree,
+	modTime time.Time,
+) *ObjectTreeNode {
+	return &ObjectTreeNode{
+		repository: repository,
+		options:    options,
+		revision:   revision,
+		tree:       tree,
+		modTime:    modTime,
 	}
 }
 
-func (node *ObjectTreeNode) Lookup(ctx context.Context, name string, _ *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+// OnForget releases the inode number of the node.
+func (node *ObjectTreeNode) OnForget() {
+	node.options.inodeTable().release(node.StableAttr().Ino)
+}
+
+// Lookup returns a file or a subtree node of the tree entry.
+// Tree entries never change, so the kernel caches them for a long time.
+func (node *ObjectTreeNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	logger := slog.Default().With(slog.String("lookupEntryName", name))
 	entry, err := node.tree.FindEntry(name)
 	if err != nil {
@@ -85,7 +125,9 @@ func (node *ObjectTreeNode) Lookup(ctx context.Context, name string, _ *fuse.Ent
 			return nil, syscall.ENOENT
 		}
 		logger.Info("File object found")
-		return node.NewInode(ctx, NewFileNode(file, node.commit), fs.StableAttr{Mode: syscall.S_IFREG}), 0
+		setImmutableEntryTimeout(out)
+		fileNode := NewFileNode(file, node.revision, node.commit, node.fileModTime(name, file))
+		return newEntryInode(ctx, &node.Inode, out, fileNode, node.options.fileStableAttr(fileNode)), 0
 	}
 
 	tree, err := object.GetTree(node.repository.Storer, entry.Hash)
@@ -94,33 +136,97 @@ func (node *ObjectTreeNode) Lookup(ctx context.Context, name string, _ *fuse.Ent
 		return nil, syscall.ENOENT
 	}
 	logger.Info("Directory object tree found")
+	setImmutableEntryTimeout(out)
 
-	return node.NewInode(
+	return newEntryInode(
 		ctx,
-		NewObjectTreeNode(node.repository, node.revision, node.commit, tree),
-		fs.StableAttr{Mode: syscall.S_IFDIR},
+		&node.Inode,
+		out,
+		&ObjectTreeNode{
+			repository: node.repository,
+			options:    node.options,
+			revision:   node.revision,
+			commit:     node.commit,
+			tree:       tree,
+			path:       path.Join(node.path, name),
+			modTime:    node.modTime,
+		},
+		node.options.treeStableAttr(&node.Inode, name, tree),
 	), 0
 }
 
+// fileModTime returns the modification time of the file with the name in the tree.
+// It is the time of the most recent commit that modified the file if the option is enabled,
+// otherwise it is the modification time of the tree.
+func (node *ObjectTreeNode) fileModTime(name string, file *object.File) time.Time {
+	if node.commit == nil || !node.options.FileModTimeFromHistory {
+		return node.modTime
+	}
+	modTime, ok := node.options.modTimes.lastModified(node.repository, node.commit, path.Join(node.path, name), file)
+	if !ok {
+		return node.modTime
+	}
+	return modTime
+}
+
 func (node *ObjectTreeNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
 	slog.Default().Info("Dir of object tree has been read")
 	return iter.NewDirStreamAdapter[object.TreeEntry](
 		iter.NewSliceIter(node.tree.Entries),
-		func(entry object.TreeEntry) fuse.DirEntry {
-			var mode uint32 = fuse.S_IFREG
-			if !entry.Mode.IsFile() {
-				mode = fuse.S_IFDIR
-			}
-			return fuse.DirEntry{
-				Name: entry.Name,
-				Mode: mode,
-			}
-		},
+		treeDirEntry,
 	), 0
 }
 
+// treeDirEntry converts the tree entry to the directory entry.
+func treeDirEntry(entry object.TreeEntry) fuse.DirEntry {
+	var mode uint32 = fuse.S_IFREG
+	if !entry.Mode.IsFile() {
+		mode = fuse.S_IFDIR
+	}
+	return fuse.DirEntry{
+		Name: entry.Name,
+		Mode: mode,
+	}
+}
+
+// Getattr returns the directory attributes.
+// Subtrees and submodules are counted as subdirectories.
 func (node *ObjectTreeNode) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
 	slog.Default().Debug("Got object tree attrs")
-	out.Mtime = uint64(node.commit.Committer.When.Unix())
+	subdirs := 0
+	for _, entry := range node.tree.Entries {
+		if !entry.Mode.IsFile() {
+			subdirs++
+		}
+	}
+	setDirAttr(&out.Attr, subdirs)
+	out.SetTimes(&node.modTime, &node.modTime, &node.modTime)
+	out.SetTimeout(immutableCacheTimeout)
 	return 0
 }
+
+// Getxattr returns the extended attribute value.
+func (node *ObjectTreeNode) Getxattr(_ context.Context, attr string, dest []byte) (uint32, syscall.Errno) {
+	return getxattr(node.xattrs(), attr, dest)
+}
+
+// Listxattr returns the extended attribute names.
+func (node *ObjectTreeNode) Listxattr(_ context.Context, dest []byte) (uint32, syscall.Errno) {
+	return listxattr(node.xattrs(), dest)
+}
+
+// xattrs returns the extended attributes of the tree: the tree hash, the mode,
+// the commit hash and the revision the tree belongs to.
+// The commit signature verification status is exposed if the verifier is configured.
+func (node *ObjectTreeNode) xattrs() []xattr {
+	attrs := []xattr{
+		{name: treeXattrName, value: node.tree.Hash.String()},
+		{name: modeXattrName, value: formatMode(filemode.Dir)},
+	}
+	attrs = appendCommitXattrs(attrs, node.revision, node.commit)
+	if node.commit != nil && node.options.Verifier != nil {
+		result := node.options.Verifier.VerifyCommit(node.commit)
+		attrs = append(attrs, xattr{name: signatureXattrName, value: string(result.Status)})
+	}
+	return attrs
+}
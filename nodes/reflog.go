@@ -0,0 +1,260 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/reflog"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	_ fs.InodeEmbedder = (*ReflogNode)(nil)
+	_ fs.NodeReaddirer = (*ReflogNode)(nil)
+	_ fs.NodeLookuper  = (*ReflogNode)(nil)
+	_ fs.NodeGetattrer = (*ReflogNode)(nil)
+
+	_ fs.InodeEmbedder = (*ReflogEntriesNode)(nil)
+	_ fs.NodeReaddirer = (*ReflogEntriesNode)(nil)
+	_ fs.NodeLookuper  = (*ReflogEntriesNode)(nil)
+	_ fs.NodeGetattrer = (*ReflogEntriesNode)(nil)
+)
+
+const reflogFileName = "log"
+
+// ReflogNode is a filesystem node that represents a list of references having reflogs.
+// The root reflog node contains "HEAD" directory and directories of branches.
+// As in BranchesNode, branch names containing directory separator are split into nested directories.
+// Each reference directory is represented by ReflogEntriesNode.
+type ReflogNode struct {
+	fs.Inode
+	repository   *git.Repository
+	options      *Options
+	branchPrefix string
+}
+
+// NewReflogNode creates a new ReflogNode.
+// The branchPrefix is empty for the root reflog node.
+func NewReflogNode(repository *git.Repository, options *Options, branchPrefix string) *ReflogNode {
+	return &ReflogNode{repository: repository, options: options, branchPrefix: branchPrefix}
+}
+
+// Lookup returns a ReflogEntriesNode if the name is HEAD or a branch having reflog,
+// or a nested ReflogNode if the name is a segment of such branches.
+// It returns ENOENT if the name is not found.
+func (node *ReflogNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().
+		With(slog.String("lookupReflogName", name)).
+		With(slog.String("branchPrefix", node.branchPrefix))
+	if node.branchPrefix == "" && name == plumbing.HEAD.String() {
+		hasHead, err := hasHeadReflog(node.repository)
+		if err != nil {
+			logger.Error("Error lookup HEAD reflog", slog.String("error", err.Error()))
+			return nil, syscall.ENOENT
+		}
+		if !hasHead {
+			logger.Warn("HEAD reflog not found")
+			return nil, syscall.ENOENT
+		}
+		logger.Info("HEAD reflog found")
+		return newEntryInode(
+			ctx,
+			&node.Inode,
+			out,
+			NewReflogEntriesNode(node.repository, node.options, plumbing.HEAD),
+			node.options.pathStableAttr(&node.Inode, name),
+		), 0
+	}
+	refNames, err := reflog.List(node.repository.Storer, revisionBranchName(node.branchPrefix))
+	if err != nil {
+		logger.Error("Error lookup reflog", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	revision := revisionBranchName(node.branchPrefix + name)
+	hasPrefix := false
+	for _, refName := range refNames {
+		if refName.String() == revision {
+			logger.Info("Branch reflog found")
+			return newEntryInode(
+				ctx,
+				&node.Inode,
+				out,
+				NewReflogEntriesNode(node.repository, node.options, refName),
+				node.options.pathStableAttr(&node.Inode, name),
+			), 0
+		}
+		if strings.HasPrefix(refName.String(), revision+branchNameSeparator) {
+			hasPrefix = true
+		}
+	}
+	if !hasPrefix {
+		logger.Warn("Reflog not found")
+		return nil, syscall.ENOENT
+	}
+	logger.Info("Reflog segment found")
+
+	return newEntryInode(
+		ctx,
+		&node.Inode,
+		out,
+		NewReflogNode(node.repository, node.options, node.branchPrefix+name+branchNameSeparator),
+		node.options.pathStableAttr(&node.Inode, name),
+	), 0
+}
+
+// Readdir returns a list of references having reflogs.
+func (node *ReflogNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	refNames, err := reflog.List(node.repository.Storer, revisionBranchName(node.branchPrefix))
+	if err != nil {
+		slog.Default().Error("Error read reflogs", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	names := make(map[string]struct{})
+	if node.branchPrefix == "" {
+		hasHead, err := hasHeadReflog(node.repository)
+		if err != nil {
+			slog.Default().Error("Error read HEAD reflog", slog.String("error", err.Error()))
+			return nil, syscall.ENOENT
+		}
+		if hasHead {
+			names[plumbing.HEAD.String()] = struct{}{}
+		}
+	}
+	for _, refName := range refNames {
+		branchName := strings.TrimPrefix(bareBranchName(refName.String()), node.branchPrefix)
+		names[strings.Split(branchName, branchNameSeparator)[0]] = struct{}{}
+	}
+	entries := make([]fuse.DirEntry, 0, len(names))
+	for name := range names {
+		entries = append(entries, fuse.DirEntry{Name: name, Mode: syscall.S_IFDIR})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	slog.Default().Info("Dir of repository reflogs has been read", slog.String("branchPrefix", node.branchPrefix))
+
+	return fs.NewListDirStream(entries), 0
+}
+
+// hasHeadReflog reports whether the repository has the reflog of HEAD.
+func hasHeadReflog(repository *git.Repository) (bool, error) {
+	refNames, err := reflog.List(repository.Storer, plumbing.HEAD.String())
+	if err != nil {
+		return false, err
+	}
+	for _, refName := range refNames {
+		if refName == plumbing.HEAD {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReflogEntriesNode is a filesystem node that represents the reflog of a reference.
+// It contains a directory for each reflog entry named by its position in the reflog:
+// "0" is the current value of the reference, "1" is the previous one and so on,
+// the same as "<ref>@{n}" notation of git. Each directory contains the tree of the entry commit.
+// The entries deleting the reference have no commit, so they have no directory and their positions are skipped.
+// It also contains "log" text file listing the reflog entries.
+type ReflogEntriesNode struct {
+	fs.Inode
+	repository *git.Repository
+	options    *Options
+	refName    plumbing.ReferenceName
+}
+
+// NewReflogEntriesNode creates a new ReflogEntriesNode.
+func NewReflogEntriesNode(repository *git.Repository, options *Options, refName plumbing.ReferenceName) *ReflogEntriesNode {
+	return &ReflogEntriesNode{repository: repository, options: options, refName: refName}
+}
+
+// Lookup returns the "log" file or the tree of the reflog entry commit.
+// It returns ENOENT if the name is not found.
+func (node *ReflogEntriesNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().
+		With(slog.String("lookupReflogEntryName", name)).
+		With(slog.String("refName", node.refName.String()))
+	entries, err := reflog.Read(node.repository.Storer, node.refName)
+	if err != nil {
+		logger.Error("Error lookup reflog entry", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	if name == reflogFileName {
+		logger.Info("Reflog file found")
+		return newEntryInode(
+			ctx,
+			&node.Inode,
+			out,
+			NewTextFileNode(node.log(entries), reflogModTime(entries)),
+			fs.StableAttr{Mode: syscall.S_IFREG},
+		), 0
+	}
+	n, err := strconv.Atoi(name)
+	if err != nil || n < 0 || n >= len(entries) || strconv.Itoa(n) != name || entries[n].New.IsZero() {
+		logger.Warn("Reflog entry not found")
+		return nil, syscall.ENOENT
+	}
+	objectNode, err := NewObjectTreeNodeByRevision(node.repository, node.options, entries[n].New.String())
+	if err != nil {
+		logger.Warn("Error lookup reflog entry object tree", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	logger.Info("Reflog entry object tree found")
+
+	return newEntryInode(ctx, &node.Inode, out, objectNode, node.options.treeStableAttr(&node.Inode, name, objectNode.tree)), 0
+}
+
+// Readdir returns the "log" file and a directory for each reflog entry.
+func (node *ReflogEntriesNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	entries, err := reflog.Read(node.repository.Storer, node.refName)
+	if err != nil {
+		slog.Default().Error("Error read reflog", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	dirEntries := make([]fuse.DirEntry, 0, len(entries)+1)
+	dirEntries = append(dirEntries, fuse.DirEntry{Name: reflogFileName, Mode: syscall.S_IFREG})
+	for i, entry := range entries {
+		if entry.New.IsZero() {
+			continue
+		}
+		dirEntries = append(dirEntries, fuse.DirEntry{Name: strconv.Itoa(i), Mode: syscall.S_IFDIR})
+	}
+	slog.Default().Info("Dir of reflog has been read", slog.String("refName", node.refName.String()))
+
+	return fs.NewListDirStream(dirEntries), 0
+}
+
+// log formats the reflog entries the same way as `git reflog` does, but with full hashes.
+func (node *ReflogEntriesNode) log(entries []reflog.Entry) []byte {
+	name := node.refName.Short()
+	var buf bytes.Buffer
+	for i, entry := range entries {
+		_, _ = fmt.Fprintf(&buf, "%s %s@{%d}: %s\n", entry.New, name, i, entry.Message)
+	}
+	return buf.Bytes()
+}
+
+// reflogModTime returns the time of the latest reflog entry.
+func reflogModTime(entries []reflog.Entry) time.Time {
+	if len(entries) == 0 {
+		return time.Time{}
+	}
+	return entries[0].Committer.When
+}
+
+// Getattr returns the directory attributes.
+func (node *ReflogNode) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return setReaddirAttr(ctx, node, &out.Attr)
+}
+
+// Getattr returns the directory attributes.
+func (node *ReflogEntriesNode) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return setReaddirAttr(ctx, node, &out.Attr)
+}
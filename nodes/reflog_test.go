@@ -0,0 +1,42 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReflogDeletion(t *testing.T) {
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(repoPath string) error {
+		// The latest entry of the test branch deletes it, and HEAD has no reflog.
+		file, err := os.OpenFile(filepath.Join(repoPath, ".git", "logs", "refs", "heads", "test"), os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = file.WriteString(commits[0] + " " + plumbing.ZeroHash.String() + " gitfs <gitfs@example.com> 1700000000 +0000\tbranch: deleted\n")
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+		return os.Remove(filepath.Join(repoPath, ".git", "logs", "HEAD"))
+	})
+
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "reflog", "test"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"1", "log"}, dirEntriesNames(entries))
+	_, err = os.Stat(filepath.Join(mountPoint, "reflog", "test", "0"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	_, err = os.Stat(filepath.Join(mountPoint, "reflog", "test", "1", "testfile1"))
+	require.NoError(t, err)
+
+	entries, err = os.ReadDir(filepath.Join(mountPoint, "reflog"))
+	require.NoError(t, err)
+	require.NotContains(t, dirEntriesNames(entries), "HEAD")
+	_, err = os.Stat(filepath.Join(mountPoint, "reflog", "HEAD"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,109 @@
+package nodes
+
+import (
+	"context"
+	"github.com/dsxack/gitfs/internal/refindex"
+	"github.com/go-git/go-git/v5"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"log/slog"
+	"sync"
+)
+
+// referenceIndex returns the index of the repository references.
+// Nodes created without the root node options index the references on every call.
+func (options *Options) referenceIndex(repository *git.Repository) *refindex.Index {
+	if options == nil || options.refs == nil {
+		return refindex.New(repository.Storer, 0)
+	}
+	return options.refs
+}
+
+// refsCache caches the value derived from the repository references and objects.
+// The value is computed again once the references change, which the index of the references
+// tells by rebuilding its trie.
+type refsCache[T any] struct {
+	mu    sync.Mutex
+	refs  *refindex.Node
+	value T
+}
+
+// get returns the cached value, or the value computed if the references have changed.
+func (cache *refsCache[T]) get(index *refindex.Index, compute func() (T, error)) (T, error) {
+	var zero T
+	refs, err := index.Root()
+	if err != nil {
+		return zero, err
+	}
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.refs == refs {
+		return cache.value, nil
+	}
+	value, err := compute()
+	if err != nil {
+		return zero, err
+	}
+	cache.refs = refs
+	cache.value = value
+	return value, nil
+}
+
+// InvalidateReferences drops the index of the references, the kernel cache of the branch and tag entries
+// which no longer match the repository references, and the cached repository usage.
+// It is meant to be called after the references change, so the changes are seen immediately,
+// instead of after the kernel cache expires, which is long for the tagged content.
+func (node *RootNode) InvalidateReferences(ctx context.Context) {
+	node.options.refs.Invalidate()
+	node.invalidateUsage()
+	for _, name := range []string{"branches", "tags"} {
+		if child := node.GetChild(name); child != nil {
+			invalidateReferenceDir(ctx, child)
+		}
+	}
+}
+
+// invalidateReferenceDir drops the kernel cache of the looked up children of the reference directory
+// which are not listed by the directory anymore, or are listed with another inode number,
+// for example, a branch moved to another commit. Entries listed without an inode number,
+// for example, tag metadata files, are always dropped. Segment directories are checked recursively.
+// The directory attributes are dropped too, since the number of subdirectories might change.
+func invalidateReferenceDir(ctx context.Context, dir *fs.Inode) {
+	logger := slog.Default().With(slog.String("referenceDir", dir.Path(nil)))
+	readdirer, ok := dir.Operations().(fs.NodeReaddirer)
+	if !ok {
+		return
+	}
+	stream, errno := readdirer.Readdir(ctx)
+	if errno != 0 {
+		logger.Error("Error read reference dir", slog.String("error", errno.Error()))
+		return
+	}
+	inos := make(map[string]uint64)
+	for stream.HasNext() {
+		entry, errno := stream.Next()
+		if errno != 0 {
+			break
+		}
+		inos[entry.Name] = entry.Ino
+	}
+	stream.Close()
+
+	for name, child := range dir.Children() {
+		ino, ok := inos[name]
+		if ok && ino != 0 && ino == child.StableAttr().Ino {
+			switch child.Operations().(type) {
+			case *BranchSegmentNode, *TagSegmentNode:
+				invalidateReferenceDir(ctx, child)
+			}
+			continue
+		}
+		if errno := dir.NotifyEntry(name); errno != 0 {
+			logger.Warn("Error invalidate reference entry", slog.String("name", name), slog.String("error", errno.Error()))
+			continue
+		}
+		logger.Info("Reference entry invalidated", slog.String("name", name))
+	}
+	if errno := dir.NotifyContent(0, 0); errno != 0 {
+		logger.Warn("Error invalidate reference dir", slog.String("error", errno.Error()))
+	}
+}
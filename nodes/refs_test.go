@@ -0,0 +1,187 @@
+package nodes
+
+import (
+	"bytes"
+	"context"
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+func TestReferenceIndexRefresh(t *testing.T) {
+	var repoPath string
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(path string) error {
+		repoPath = path
+		return nil
+	})
+
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "branches"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"master", "nested", "test"}, dirEntriesNames(entries))
+
+	// Prefixes of branch names are not branch segments.
+	_, err = os.Stat(filepath.Join(mountPoint, "branches", "mas"))
+	require.True(t, os.IsNotExist(err))
+
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName("release/v1"),
+		plumbing.NewTagReferenceName("release/v1"),
+	} {
+		_, err = runGit(repoPath, "update-ref", name.String(), commits[2])
+		require.NoError(t, err)
+	}
+	// The references are not watched, so the changes are seen once the index expires.
+	time.Sleep(MutableCacheTimeout)
+
+	for _, dir := range []string{"branches", "tags"} {
+		entries, err = os.ReadDir(filepath.Join(mountPoint, dir, "release"))
+		require.NoError(t, err, dir)
+		require.Equal(t, []string{"v1"}, dirEntriesNames(entries), dir)
+		content, err := os.ReadFile(filepath.Join(mountPoint, dir, "release", "v1", "testfile3"))
+		require.NoError(t, err, dir)
+		require.Equal(t, "testfile3 content\n", string(content), dir)
+	}
+}
+
+func TestReferenceSegmentsReaddir(t *testing.T) {
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(repoPath string) error {
+		for _, name := range []plumbing.ReferenceName{
+			plumbing.NewTagReferenceName("release/2024/v1.2.4"),
+			plumbing.NewTagReferenceName("release/2024/v1.2.3"),
+			plumbing.NewTagReferenceName("release/2023/v1.0.0"),
+			plumbing.NewBranchReferenceName("nested/other"),
+		} {
+			if _, err := runGit(repoPath, "update-ref", name.String(), commits[2]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	expected := map[string][]string{
+		"tags":                {"nested", "release", "v1.0.0", "v1.0.1"},
+		"tags/release":        {"2023", "2024"},
+		"tags/release/2024":   {"v1.2.3", "v1.2.4"},
+		"branches":            {"master", "nested", "test"},
+		"branches/nested":     {"dir", "other"},
+		"branches/nested/dir": {"test"},
+	}
+	for dir, names := range expected {
+		inos := readDirInos(t, filepath.Join(mountPoint, dir))
+		require.Equal(t, names, inos.names, dir)
+		for i, name := range inos.names {
+			var stat syscall.Stat_t
+			require.NoError(t, syscall.Stat(filepath.Join(mountPoint, dir, name), &stat), name)
+			require.Equal(t, stat.Ino, inos.inos[i], filepath.Join(dir, name))
+			require.Equal(t, uint8(unix.DT_DIR), inos.types[i], filepath.Join(dir, name))
+		}
+	}
+}
+
+type dirInos struct {
+	names []string
+	inos  []uint64
+	types []uint8
+}
+
+// readDirInos reads the directory entries with their inode numbers and types as the kernel reports them.
+func readDirInos(t *testing.T, path string) dirInos {
+	t.Helper()
+	dir, err := os.Open(path)
+	require.NoError(t, err)
+	defer dir.Close()
+	var result dirInos
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := unix.ReadDirent(int(dir.Fd()), buf)
+		require.NoError(t, err)
+		if n == 0 {
+			return result
+		}
+		for off := 0; off < n; {
+			dirent := (*unix.Dirent)(unsafe.Pointer(&buf[off]))
+			nameBytes := unsafe.Slice((*byte)(unsafe.Pointer(&dirent.Name[0])), len(dirent.Name))
+			name := string(nameBytes[:bytes.IndexByte(nameBytes, 0)])
+			if name != "." && name != ".." {
+				result.names = append(result.names, name)
+				result.inos = append(result.inos, dirent.Ino)
+				result.types = append(result.types, dirent.Type)
+			}
+			off += int(dirent.Reclen)
+		}
+	}
+}
+
+func TestInvalidateReferences(t *testing.T) {
+	var (
+		root     *RootNode
+		repoPath string
+	)
+	mountPoint := testdata.InitializePrepared(t, func(repository *git.Repository) *RootNode {
+		root = NewRootNodeWithOptions(repository, Options{ReferencesWatched: true})
+		return root
+	}, func(path string) error {
+		repoPath = path
+		return nil
+	})
+
+	_, err := os.ReadFile(filepath.Join(mountPoint, "tags", "v1.0.0", "testfile1"))
+	require.NoError(t, err)
+	_, err = os.ReadFile(filepath.Join(mountPoint, "tags", "v1.0.1", "testfile2"))
+	require.NoError(t, err)
+	var before syscall.Stat_t
+	require.NoError(t, syscall.Stat(filepath.Join(mountPoint, "branches", "master"), &before))
+
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.NewTagReferenceName("v1.0.0"),
+		plumbing.NewBranchReferenceName("master"),
+	} {
+		_, err = runGit(repoPath, "update-ref", name.String(), commits[3])
+		require.NoError(t, err)
+	}
+	require.NoError(t, os.Remove(filepath.Join(repoPath, ".git", "refs", "tags", "v1.0.1")))
+
+	// The tagged content is cached by the kernel until the references are invalidated.
+	_, err = os.Stat(filepath.Join(mountPoint, "tags", "v1.0.0", "testdir"))
+	require.True(t, os.IsNotExist(err))
+
+	root.InvalidateReferences(context.Background())
+
+	content, err := os.ReadFile(filepath.Join(mountPoint, "tags", "v1.0.0", "testdir", "testfile4"))
+	require.NoError(t, err)
+	require.Equal(t, "content of testfile4\n", string(content))
+	_, err = os.Stat(filepath.Join(mountPoint, "tags", "v1.0.1"))
+	require.True(t, os.IsNotExist(err))
+	var after syscall.Stat_t
+	require.NoError(t, syscall.Stat(filepath.Join(mountPoint, "branches", "master"), &after))
+	require.NotEqual(t, before.Ino, after.Ino)
+	_, err = os.Stat(filepath.Join(mountPoint, "branches", "master", "testdir"))
+	require.NoError(t, err)
+}
+
+func TestMovedTagNotWatched(t *testing.T) {
+	var repoPath string
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(path string) error {
+		repoPath = path
+		return nil
+	})
+
+	_, err := os.ReadFile(filepath.Join(mountPoint, "tags", "v1.0.0", "testfile1"))
+	require.NoError(t, err)
+	_, err = runGit(repoPath, "update-ref", plumbing.NewTagReferenceName("v1.0.0").String(), commits[3])
+	require.NoError(t, err)
+
+	// The tag is cached by the kernel for a second only, since the references are not watched.
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(mountPoint, "tags", "v1.0.0", "testdir"))
+		return err == nil
+	}, 5*time.Second, 100*time.Millisecond)
+}
@@ -2,46 +2,128 @@ package nodes
 
 import (
 	"context"
+	"github.com/dsxack/gitfs/internal/refindex"
+	"github.com/dsxack/gitfs/internal/usage"
+	"github.com/dsxack/gitfs/internal/verify"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
+	"log/slog"
+	"sync"
 	"syscall"
 )
 
 var (
-	_ fs.InodeEmbedder = (*RootNode)(nil)
-	_ fs.NodeLookuper  = (*RootNode)(nil)
-	_ fs.NodeReaddirer = (*RootNode)(nil)
+	_ fs.InodeEmbedder   = (*RootNode)(nil)
+	_ fs.NodeLookuper    = (*RootNode)(nil)
+	_ fs.NodeGetattrer   = (*RootNode)(nil)
+	_ fs.NodeStatfser    = (*RootNode)(nil)
+	_ fs.NodeReaddirer   = (*RootNode)(nil)
+	_ fs.NodeOnForgetter = (*RootNode)(nil)
 )
 
 // RootNode is the root node of the filesystem.
 // It contains the following subdirectories:
 // - branches: list of branches
 // - commits: list of commits
+// - index: staged files of the index
+// - notes: notes of objects by notes references
+// - reflog: reflogs of HEAD and branches
+// - stash: list of stashes
 // - tags: list of tags
+// - unreachable: list of commits not reachable from references
 type RootNode struct {
 	fs.Inode
 	repository *git.Repository
+	options    Options
+
+	// usage is the repository usage read by the last Statfs, it is dropped when the references change.
+	usageMu sync.Mutex
+	usage   *usage.Usage
+
+	// release is called once the root of the repository removed from MultiRootNode is forgotten.
+	release     func()
+	releaseOnce sync.Once
+}
+
+// Options configures the filesystem presentation of the repository.
+type Options struct {
+	// ReachableCommitsOnly restricts the list of commits directory
+	// to commits reachable from the repository references.
+	ReachableCommitsOnly bool
+	// Verifier verifies signatures of commits and tags.
+	// If it is nil, the signature verification results are not exposed.
+	Verifier *verify.Verifier
+	// FileModTimeFromHistory reports the modification time of files in commit trees
+	// as the time of the most recent commit that modified the file,
+	// instead of the time of the commit being browsed.
+	FileModTimeFromHistory bool
+	// ReferencesWatched tells that InvalidateReferences is called whenever the references change,
+	// for example, by a watcher of the references or after a fetch, so they are read again only then.
+	// Otherwise, the references are read again once they are cached for MutableCacheTimeout.
+	ReferencesWatched bool
+
+	inodes    *inodeTable
+	modTimes  *modTimeCache
+	refs      *refindex.Index
+	reachable *refsCache[map[plumbing.Hash]struct{}]
+	shallow   *refsCache[map[plumbing.Hash]struct{}]
+	// repositoryName is the name of the repository subdirectory when several repositories are mounted.
+	repositoryName string
 }
 
-// NewRootNode creates a new RootNode.
+// NewRootNode creates a new RootNode with default options.
 func NewRootNode(repository *git.Repository) *RootNode {
-	return &RootNode{repository: repository}
+	return NewRootNodeWithOptions(repository, Options{})
+}
+
+// NewRootNodeWithOptions creates a new RootNode.
+func NewRootNodeWithOptions(repository *git.Repository, options Options) *RootNode {
+	options.inodes = newInodeTable()
+	options.modTimes = newModTimeCache(modTimeCacheSize)
+	refsMaxAge := MutableCacheTimeout
+	if options.ReferencesWatched {
+		refsMaxAge = 0
+	}
+	options.refs = refindex.New(repository.Storer, refsMaxAge)
+	options.reachable = &refsCache[map[plumbing.Hash]struct{}]{}
+	options.shallow = &refsCache[map[plumbing.Hash]struct{}]{}
+	return &RootNode{repository: repository, options: options}
 }
 
 // Lookup returns the inode for the given name.
 // It returns ENOENT if the name is not found.
-func (node *RootNode) Lookup(ctx context.Context, name string, _ *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+func (node *RootNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
 	switch name {
 	case "branches":
-		ops := NewBranchesNode(node.repository)
-		return node.NewInode(ctx, ops, fs.StableAttr{Mode: syscall.S_IFDIR}), 0
+		ops := NewBranchesNode(node.repository, &node.options)
+		return newUncountedDirEntryInode(ctx, &node.Inode, out, ops, node.options.pathStableAttr(&node.Inode, name)), 0
 	case "commits":
-		ops := NewCommitsNode(node.repository)
-		return node.NewInode(ctx, ops, fs.StableAttr{Mode: syscall.S_IFDIR}), 0
+		reachability := AllCommits
+		if node.options.ReachableCommitsOnly {
+			reachability = ReachableCommits
+		}
+		ops := NewCommitsNode(node.repository, &node.options, reachability)
+		return newEntryInode(ctx, &node.Inode, out, ops, node.options.pathStableAttr(&node.Inode, name)), 0
+	case "index":
+		ops := NewIndexNode(node.repository, &node.options, "")
+		return newEntryInode(ctx, &node.Inode, out, ops, node.options.pathStableAttr(&node.Inode, name)), 0
+	case "notes":
+		ops := NewNotesNode(node.repository, &node.options, "")
+		return newEntryInode(ctx, &node.Inode, out, ops, node.options.pathStableAttr(&node.Inode, name)), 0
+	case "reflog":
+		ops := NewReflogNode(node.repository, &node.options, "")
+		return newEntryInode(ctx, &node.Inode, out, ops, node.options.pathStableAttr(&node.Inode, name)), 0
+	case "stash":
+		ops := NewStashNode(node.repository, &node.options)
+		return newEntryInode(ctx, &node.Inode, out, ops, node.options.pathStableAttr(&node.Inode, name)), 0
 	case "tags":
-		ops := NewTagsNode(node.repository)
-		return node.NewInode(ctx, ops, fs.StableAttr{Mode: syscall.S_IFDIR}), 0
+		ops := NewTagsNode(node.repository, &node.options)
+		return newUncountedDirEntryInode(ctx, &node.Inode, out, ops, node.options.pathStableAttr(&node.Inode, name)), 0
+	case "unreachable":
+		ops := NewCommitsNode(node.repository, &node.options, UnreachableCommits)
+		return newEntryInode(ctx, &node.Inode, out, ops, node.options.pathStableAttr(&node.Inode, name)), 0
 	}
 	return nil, syscall.ENOENT
 }
@@ -51,6 +133,74 @@ func (node *RootNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
 	return fs.NewListDirStream([]fuse.DirEntry{
 		{Name: "branches", Mode: syscall.S_IFDIR},
 		{Name: "commits", Mode: syscall.S_IFDIR},
+		{Name: "index", Mode: syscall.S_IFDIR},
+		{Name: "notes", Mode: syscall.S_IFDIR},
+		{Name: "reflog", Mode: syscall.S_IFDIR},
+		{Name: "stash", Mode: syscall.S_IFDIR},
 		{Name: "tags", Mode: syscall.S_IFDIR},
+		{Name: "unreachable", Mode: syscall.S_IFDIR},
 	}), 0
 }
+
+// Getattr returns the directory attributes.
+func (node *RootNode) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return setReaddirAttr(ctx, node, &out.Attr)
+}
+
+// statfsBlockSize is the block size reported by Statfs.
+const statfsBlockSize = 4096
+
+// Statfs returns the filesystem statistics: the size of the repository pack files and loose objects
+// as used blocks and the number of objects as used inodes.
+// The filesystem is read-only, so there is no free space and no free inodes.
+func (node *RootNode) Statfs(_ context.Context, out *fuse.StatfsOut) syscall.Errno {
+	repositoryUsage, err := node.readUsage()
+	if err != nil {
+		slog.Default().Error("Error read repository usage", slog.String("error", err.Error()))
+		return syscall.EIO
+	}
+	setStatfs(out, repositoryUsage)
+	return 0
+}
+
+// readUsage returns the repository usage. Objects are added together with the references
+// pointing to them, so the usage is read once and cached until the references change.
+func (node *RootNode) readUsage() (usage.Usage, error) {
+	node.usageMu.Lock()
+	defer node.usageMu.Unlock()
+	if node.usage != nil {
+		return *node.usage, nil
+	}
+	repositoryUsage, err := usage.Read(node.repository.Storer)
+	if err != nil {
+		return usage.Usage{}, err
+	}
+	node.usage = &repositoryUsage
+	return repositoryUsage, nil
+}
+
+// invalidateUsage drops the cached repository usage.
+func (node *RootNode) invalidateUsage() {
+	node.usageMu.Lock()
+	defer node.usageMu.Unlock()
+	node.usage = nil
+}
+
+// OnForget calls the release function of the repository removed from MultiRootNode.
+// The root is persistent while the repository is mounted, so it is forgotten only after the removal,
+// once the kernel forgets the nodes of the repository.
+func (node *RootNode) OnForget() {
+	node.releaseOnce.Do(func() {
+		if node.release != nil {
+			node.release()
+		}
+	})
+}
+
+func setStatfs(out *fuse.StatfsOut, repositoryUsage usage.Usage) {
+	out.Bsize = statfsBlockSize
+	out.Frsize = statfsBlockSize
+	out.Blocks = (repositoryUsage.Size + statfsBlockSize - 1) / statfsBlockSize
+	out.Files = repositoryUsage.Objects
+	out.NameLen = 255
+}
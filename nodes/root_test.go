@@ -1,11 +1,15 @@
 package nodes
 
 import (
+	"errors"
+	"fmt"
 	"github.com/dsxack/gitfs/internal/testdata"
 	"github.com/stretchr/testify/require"
 	"io/fs"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"testing/fstest"
 )
@@ -38,6 +42,44 @@ var commitFiles = map[string]fstest.MapFS{
 	},
 }
 
+type reflogEntry struct {
+	hash    string
+	message string
+}
+
+var reflogs = map[string][]reflogEntry{
+	"HEAD": {
+		{commits[3], "reset: moving to " + commits[3]},
+		{commits[1], "checkout: moving from master to nested/dir/test"},
+		{commits[1], "checkout: moving from nested/dir to master"},
+		{commits[3], "checkout: moving from nested-dir to nested/dir"},
+		{commits[3], "checkout: moving from nested/test to nested-dir"},
+		{commits[2], "reset: moving to HEAD~1"},
+		{commits[3], "checkout: moving from nested-dir to nested/test"},
+		{commits[3], "checkout: moving from nested/test to nested-dir"},
+		{commits[3], "commit: add testfile 4"},
+		{commits[2], "commit: nested branch name test"},
+		{commits[1], "checkout: moving from master to nested/test"},
+		{commits[1], "checkout: moving from test to master"},
+		{commits[0], "checkout: moving from master to test"},
+		{commits[1], "commit: second commit"},
+		{commits[0], "checkout: moving from test to master"},
+		{commits[0], "checkout: moving from master to test"},
+		{commits[0], "commit (initial): init commit"},
+	},
+	"master": {
+		{commits[1], "commit: second commit"},
+		{commits[0], "commit (initial): init commit"},
+	},
+	"test": {
+		{commits[0], "branch: Created from master"},
+	},
+	"nested/dir/test": {
+		{commits[3], "reset: moving to " + commits[3]},
+		{commits[1], "branch: Created from HEAD"},
+	},
+}
+
 var expectedFS = combineMapFS(
 	prefixMapFS("branches/test/", commitFiles[commits[0]]),
 	prefixMapFS("branches/master/", commitFiles[commits[1]]),
@@ -49,12 +91,23 @@ var expectedFS = combineMapFS(
 	prefixMapFS("commits/"+commits[1]+"/", commitFiles[commits[1]]),
 	prefixMapFS("commits/"+commits[2]+"/", commitFiles[commits[2]]),
 	prefixMapFS("commits/"+commits[3]+"/", commitFiles[commits[3]]),
+	prefixMapFS("index/", commitFiles[commits[3]]),
+	fstest.MapFS{"notes": mapDir()},
+	fstest.MapFS{"stash": mapDir()},
+	fstest.MapFS{"unreachable": mapDir()},
+	reflogMapFS("HEAD", reflogs["HEAD"]),
+	reflogMapFS("master", reflogs["master"]),
+	reflogMapFS("test", reflogs["test"]),
+	reflogMapFS("nested/dir/test", reflogs["nested/dir/test"]),
 )
 
 func TestLookup(t *testing.T) {
 	mountPoint := testdata.Initialize(t, NewRootNode)
 
 	for path, expected := range expectedFS {
+		if expected.Mode.IsDir() {
+			continue
+		}
 		t.Run(path, func(t *testing.T) {
 			actual, _ := os.ReadFile(filepath.Join(mountPoint, path))
 			require.Equal(t, string(expected.Data), string(actual))
@@ -96,6 +149,21 @@ func mapFile(content string) *fstest.MapFile {
 	}
 }
 
+func mapDir() *fstest.MapFile {
+	return &fstest.MapFile{Mode: fs.ModeDir | 0555}
+}
+
+func reflogMapFS(name string, entries []reflogEntry) fstest.MapFS {
+	newFS := fstest.MapFS{}
+	log := ""
+	for i, entry := range entries {
+		log += fmt.Sprintf("%s %s@{%d}: %s\n", entry.hash, name, i, entry.message)
+		newFS = combineMapFS(newFS, prefixMapFS(fmt.Sprintf("reflog/%s/%d/", name, i), commitFiles[entry.hash]))
+	}
+	newFS["reflog/"+name+"/log"] = mapFile(log)
+	return newFS
+}
+
 func prefixMapFS(prefix string, fs fstest.MapFS) fstest.MapFS {
 	newFS := fstest.MapFS{}
 	for k, v := range fs {
@@ -125,3 +193,34 @@ func dirEntriesNames[T dirEntry](entries []T) []string {
 	}
 	return names
 }
+
+// gitDate is the author and committer date of the objects created by runGit.
+const gitDate = "1681128000 +0000"
+
+// runGit runs git in the repository directory and returns its output without the trailing newline.
+func runGit(dir string, args ...string) (string, error) {
+	return runGitInput(dir, "", args...)
+}
+
+// runGitInput runs git in the repository directory with the input, see runGit.
+func runGitInput(dir, input string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Env = append(
+		os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_AUTHOR_NAME=gitfs", "GIT_AUTHOR_EMAIL=gitfs@example.com", "GIT_AUTHOR_DATE="+gitDate,
+		"GIT_COMMITTER_NAME=gitfs", "GIT_COMMITTER_EMAIL=gitfs@example.com", "GIT_COMMITTER_DATE="+gitDate,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, exitErr.Stderr)
+		}
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return strings.TrimSuffix(string(output), "\n"), nil
+}
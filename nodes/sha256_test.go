@@ -0,0 +1,49 @@
+//go:build sha256
+
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSHA256Repository(t *testing.T) {
+	var head string
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(repoPath string) error {
+		// The test repository is replaced with the SHA-256 one created by git itself.
+		if err := os.RemoveAll(filepath.Join(repoPath, ".git")); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(repoPath, "testfile1"), []byte("sha256 content\n"), 0644); err != nil {
+			return err
+		}
+		for _, args := range [][]string{
+			{"init", "-q", "-b", "master", "--object-format=sha256"},
+			{"add", "testfile1"},
+			{"commit", "-q", "-m", "first"},
+		} {
+			if _, err := runGit(repoPath, args...); err != nil {
+				return err
+			}
+		}
+		var err error
+		head, err = runGit(repoPath, "rev-parse", "HEAD")
+		return err
+	})
+	require.Len(t, head, 64)
+
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "commits"))
+	require.NoError(t, err)
+	require.Equal(t, []string{head}, dirEntriesNames(entries))
+
+	content, err := os.ReadFile(filepath.Join(mountPoint, "commits", head, "testfile1"))
+	require.NoError(t, err)
+	require.Equal(t, "sha256 content\n", string(content))
+
+	content, err = os.ReadFile(filepath.Join(mountPoint, "branches", "master", "testfile1"))
+	require.NoError(t, err)
+	require.Equal(t, "sha256 content\n", string(content))
+}
@@ -0,0 +1,60 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/dsxack/gitfs/internal/verify"
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignatureVerification(t *testing.T) {
+	verifier, err := verify.NewVerifier("", "")
+	require.NoError(t, err)
+	mountPoint := testdata.InitializePrepared(t, func(repository *git.Repository) *RootNode {
+		return NewRootNodeWithOptions(repository, Options{Verifier: verifier})
+	}, func(repoPath string) error {
+		_, err := runGit(repoPath, "tag", "-a", "-m", "Release v2.0.0", "v2.0.0", commits[3])
+		return err
+	})
+
+	// Commits of the test repository are signed by the key missing from the empty keyring.
+	expected := map[string]string{
+		"commits/" + commits[0] + ".signature": "status: unknown-key\n" +
+			"type: pgp\n" +
+			"error: openpgp: signature made by unknown entity\n",
+		"tags/v2.0.0.signature": "status: unsigned\n",
+	}
+	for path, content := range expected {
+		actual, err := os.ReadFile(filepath.Join(mountPoint, path))
+		require.NoError(t, err, path)
+		require.Equal(t, content, string(actual), path)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "commits"))
+	require.NoError(t, err)
+	require.Contains(t, dirEntriesNames(entries), commits[0]+".signature")
+
+	entries, err = os.ReadDir(filepath.Join(mountPoint, "tags"))
+	require.NoError(t, err)
+	require.Contains(t, dirEntriesNames(entries), "v2.0.0.signature")
+	require.NotContains(t, dirEntriesNames(entries), "v1.0.0.signature")
+
+	dest := make([]byte, 64)
+	n, err := unix.Getxattr(filepath.Join(mountPoint, "commits", commits[0]), "user.git.signature", dest)
+	require.NoError(t, err)
+	require.Equal(t, "unknown-key", string(dest[:n]))
+}
+
+func TestSignatureVerificationDisabled(t *testing.T) {
+	mountPoint := testdata.Initialize(t, NewRootNode)
+
+	_, err := os.Stat(filepath.Join(mountPoint, "commits", commits[0]+".signature"))
+	require.True(t, os.IsNotExist(err))
+
+	_, err = unix.Getxattr(filepath.Join(mountPoint, "commits", commits[0]), "user.git.signature", nil)
+	require.ErrorIs(t, err, unix.ENODATA)
+}
@@ -0,0 +1,238 @@
+package nodes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/reflog"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"log/slog"
+	"strconv"
+	"syscall"
+)
+
+var (
+	_ fs.InodeEmbedder = (*StashNode)(nil)
+	_ fs.NodeReaddirer = (*StashNode)(nil)
+	_ fs.NodeLookuper  = (*StashNode)(nil)
+	_ fs.NodeGetattrer = (*StashNode)(nil)
+
+	_ fs.InodeEmbedder = (*StashEntryNode)(nil)
+	_ fs.NodeReaddirer = (*StashEntryNode)(nil)
+	_ fs.NodeGetattrer = (*StashEntryNode)(nil)
+	_ fs.NodeLookuper  = (*StashEntryNode)(nil)
+)
+
+const (
+	stashReferenceName = plumbing.ReferenceName("refs/stash")
+
+	stashIndexDirName     = "index"
+	stashUntrackedDirName = "untracked"
+)
+
+// StashNode is a filesystem node that represents a list of stashes.
+// It is a directory that contains a directory for each stash entry
+// named by its position in the stash list: "0" is the latest stash, "1" is the previous one and so on.
+// The stash list is read from the refs/stash reflog.
+type StashNode struct {
+	fs.Inode
+	repository *git.Repository
+	options    *Options
+}
+
+// NewStashNode creates a new StashNode.
+func NewStashNode(repository *git.Repository, options *Options) *StashNode {
+	return &StashNode{repository: repository, options: options}
+}
+
+// Lookup returns a stash entry node by its position in the stash list.
+// It returns ENOENT if the name is not found.
+func (node *StashNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().With(slog.String("lookupStashName", name))
+	n, err := strconv.Atoi(name)
+	if err != nil || n < 0 || strconv.Itoa(n) != name {
+		logger.Warn("Stash not found")
+		return nil, syscall.ENOENT
+	}
+	stashes, err := node.stashes()
+	if err != nil {
+		logger.Error("Error lookup stash", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	if n >= len(stashes) {
+		logger.Warn("Stash not found")
+		return nil, syscall.ENOENT
+	}
+	stashNode, err := NewStashEntryNode(node.repository, node.options, fmt.Sprintf("stash@{%d}", n), stashes[n])
+	if err != nil {
+		logger.Error("Error lookup stash object tree", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	logger.Info("Stash object tree found")
+
+	return newEntryInode(ctx, &node.Inode, out, stashNode, node.options.treeStableAttr(&node.Inode, name, stashNode.tree)), 0
+}
+
+// Readdir returns a list of stash entries.
+func (node *StashNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	stashes, err := node.stashes()
+	if err != nil {
+		slog.Default().Error("Error read stashes", slog.String("error", err.Error()))
+		return nil, syscall.ENOENT
+	}
+	entries := make([]fuse.DirEntry, len(stashes))
+	for i := range stashes {
+		entries[i] = fuse.DirEntry{Name: strconv.Itoa(i), Mode: syscall.S_IFDIR}
+	}
+	slog.Default().Info("Dir of repository stashes has been read")
+	return fs.NewListDirStream(entries), 0
+}
+
+// stashes returns the hashes of stash commits from the latest to the oldest.
+// When the storage keeps no reflogs, only the latest stash pointed by refs/stash is returned.
+func (node *StashNode) stashes() ([]plumbing.Hash, error) {
+	entries, err := reflog.Read(node.repository.Storer, stashReferenceName)
+	if err == nil {
+		hashes := make([]plumbing.Hash, len(entries))
+		for i, entry := range entries {
+			hashes[i] = entry.New
+		}
+		return hashes, nil
+	}
+	if !errors.Is(err, reflog.ErrNotFound) {
+		return nil, err
+	}
+	ref, err := node.repository.Reference(stashReferenceName, true)
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []plumbing.Hash{ref.Hash()}, nil
+}
+
+// StashEntryNode is a node that represents a single stash entry.
+// It contains the stashed working tree, and additionally "index" directory
+// with the stashed staging area and "untracked" directory with the stashed
+// untracked files if the stash was created with --include-untracked.
+type StashEntryNode struct {
+	ObjectTreeNode
+	indexTree     *object.Tree
+	untrackedTree *object.Tree
+}
+
+// NewStashEntryNode creates a new StashEntryNode by the stash commit hash.
+// Stash commit has the HEAD commit as the first parent, the index commit as the second one
+// and optionally the untracked files commit as the third one.
+func NewStashEntryNode(repository *git.Repository, options *Options, revision string, hash plumbing.Hash) (*StashEntryNode, error) {
+	commit, err := repository.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("repository: commit object: %v", err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("commit tree: %v", err)
+	}
+	node := &StashEntryNode{
+		ObjectTreeNode: ObjectTreeNode{
+			repository: repository,
+			options:    options,
+			revision:   revision,
+			commit:     commit,
+			tree:       tree,
+			modTime:    commit.Committer.When,
+		},
+	}
+	if commit.NumParents() > 1 {
+		node.indexTree, err = parentTree(commit, 1)
+		if err != nil {
+			return nil, fmt.Errorf("stash index tree: %v", err)
+		}
+	}
+	if commit.NumParents() > 2 {
+		node.untrackedTree, err = parentTree(commit, 2)
+		if err != nil {
+			return nil, fmt.Errorf("stash untracked tree: %v", err)
+		}
+	}
+	return node, nil
+}
+
+// Lookup returns the stashed index or untracked files directories,
+// otherwise looks up the entry in the stashed working tree.
+func (node *StashEntryNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	tree := node.extraTrees()[name]
+	if tree == nil {
+		return node.ObjectTreeNode.Lookup(ctx, name, out)
+	}
+	slog.Default().Info("Stash object tree found", slog.String("lookupEntryName", name))
+	setImmutableEntryTimeout(out)
+
+	return newEntryInode(
+		ctx,
+		&node.Inode,
+		out,
+		NewObjectTreeNode(node.repository, node.options, node.revision, node.commit, tree),
+		node.options.treeStableAttr(&node.Inode, name, tree),
+	), 0
+}
+
+// Readdir returns the entries of the stashed working tree
+// together with the stashed index and untracked files directories.
+// The stash directories shadow the working tree entries with the same names.
+func (node *StashEntryNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	extraTrees := node.extraTrees()
+	entries := make([]fuse.DirEntry, 0, len(node.tree.Entries)+len(extraTrees))
+	for _, entry := range node.tree.Entries {
+		if extraTrees[entry.Name] != nil {
+			continue
+		}
+		entries = append(entries, treeDirEntry(entry))
+	}
+	for _, name := range []string{stashIndexDirName, stashUntrackedDirName} {
+		if extraTrees[name] != nil {
+			entries = append(entries, fuse.DirEntry{Name: name, Mode: syscall.S_IFDIR})
+		}
+	}
+	slog.Default().Info("Dir of stash has been read", slog.String("revision", node.revision))
+	return fs.NewListDirStream(entries), 0
+}
+
+// Getattr returns the directory attributes counting the stash directories as subdirectories.
+func (node *StashEntryNode) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	if errno := setReaddirAttr(ctx, node, &out.Attr); errno != 0 {
+		return errno
+	}
+	out.SetTimes(&node.modTime, &node.modTime, &node.modTime)
+	out.SetTimeout(immutableCacheTimeout)
+	return 0
+}
+
+func (node *StashEntryNode) extraTrees() map[string]*object.Tree {
+	trees := make(map[string]*object.Tree, 2)
+	if node.indexTree != nil {
+		trees[stashIndexDirName] = node.indexTree
+	}
+	if node.untrackedTree != nil {
+		trees[stashUntrackedDirName] = node.untrackedTree
+	}
+	return trees
+}
+
+func parentTree(commit *object.Commit, n int) (*object.Tree, error) {
+	parent, err := commit.Parent(n)
+	if err != nil {
+		return nil, err
+	}
+	return parent.Tree()
+}
+
+// Getattr returns the directory attributes.
+func (node *StashNode) Getattr(ctx context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return setReaddirAttr(ctx, node, &out.Attr)
+}
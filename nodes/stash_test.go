@@ -0,0 +1,71 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStash(t *testing.T) {
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(repoPath string) error {
+		// Both stashes have the staged and the changed testfile1, the latest one has the untracked file too.
+		for _, stash := range []struct {
+			staged, changed, untracked string
+		}{
+			{staged: "older staged\n", changed: "older changed\n"},
+			{staged: "staged\n", changed: "changed\n", untracked: "untracked\n"},
+		} {
+			if err := os.WriteFile(filepath.Join(repoPath, "testfile1"), []byte(stash.staged), 0644); err != nil {
+				return err
+			}
+			if _, err := runGit(repoPath, "add", "testfile1"); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(repoPath, "testfile1"), []byte(stash.changed), 0644); err != nil {
+				return err
+			}
+			args := []string{"stash", "push", "-q"}
+			if stash.untracked != "" {
+				if err := os.WriteFile(filepath.Join(repoPath, "untrackedfile"), []byte(stash.untracked), 0644); err != nil {
+					return err
+				}
+				args = append(args, "--include-untracked")
+			}
+			if _, err := runGit(repoPath, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	expected := map[string]string{
+		"stash/0/testfile1":               "changed\n",
+		"stash/0/testfile2":               "testfile2 content\n",
+		"stash/0/index/testfile1":         "staged\n",
+		"stash/0/untracked/untrackedfile": "untracked\n",
+		"stash/1/testfile1":               "older changed\n",
+		"stash/1/index/testfile1":         "older staged\n",
+	}
+	for path, content := range expected {
+		actual, err := os.ReadFile(filepath.Join(mountPoint, path))
+		require.NoError(t, err, path)
+		require.Equal(t, content, string(actual), path)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "stash"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "1"}, dirEntriesNames(entries))
+
+	entries, err = os.ReadDir(filepath.Join(mountPoint, "stash", "0"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"testfile1", "testfile2", "testfile3", "testdir", "index", "untracked"}, dirEntriesNames(entries))
+
+	entries, err = os.ReadDir(filepath.Join(mountPoint, "stash", "1"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"testfile1", "testfile2", "testfile3", "testdir", "index"}, dirEntriesNames(entries))
+
+	_, err = os.Stat(filepath.Join(mountPoint, "stash", "2"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,41 @@
+package nodes
+
+import (
+	"context"
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+	"syscall"
+	"testing"
+)
+
+func TestStatfs(t *testing.T) {
+	var repository *git.Repository
+	var root *RootNode
+	mountPoint := testdata.Initialize(t, func(r *git.Repository) *RootNode {
+		repository = r
+		root = NewRootNode(r)
+		return root
+	})
+
+	var stat syscall.Statfs_t
+	require.NoError(t, syscall.Statfs(mountPoint, &stat))
+	// The test repository keeps 13 loose objects.
+	require.Equal(t, uint64(13), stat.Files)
+	require.NotZero(t, stat.Blocks)
+	require.Zero(t, stat.Bfree)
+	require.Zero(t, stat.Bavail)
+	require.Zero(t, stat.Ffree)
+
+	// The usage is cached until the references change.
+	blob := repository.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	_, err := repository.Storer.SetEncodedObject(blob)
+	require.NoError(t, err)
+	require.NoError(t, syscall.Statfs(mountPoint, &stat))
+	require.Equal(t, uint64(13), stat.Files)
+	root.InvalidateReferences(context.Background())
+	require.NoError(t, syscall.Statfs(mountPoint, &stat))
+	require.Equal(t, uint64(14), stat.Files)
+}
@@ -2,25 +2,37 @@ package nodes
 
 import (
 	"context"
-	"github.com/dsxack/gitfs/internal/iter"
+	"fmt"
+	"github.com/dsxack/gitfs/internal/refindex"
+	"github.com/dsxack/gitfs/internal/verify"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/hanwen/go-fuse/v2/fs"
 	"github.com/hanwen/go-fuse/v2/fuse"
+	"io"
 	"log/slog"
+	"path"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 )
 
 var (
 	_ fs.InodeEmbedder = (*TagsNode)(nil)
 	_ fs.NodeReaddirer = (*TagsNode)(nil)
 	_ fs.NodeLookuper  = (*TagsNode)(nil)
+	_ fs.NodeGetattrer = (*TagsNode)(nil)
 )
 
 const tagNameSeparator = string(filepath.Separator)
 
+// tagMetadataFileSuffix is the suffix of the annotated tag metadata file name.
+// For example, the metadata of "v1.0.0" tag is exposed as "v1.0.0.tag" file next to the tag directory.
+const tagMetadataFileSuffix = ".tag"
+
 // TagsNode is a node that represents a git repository's tags.
 // It is a directory that contains a directory for each tag.
 // If tag contains directory separator, it will be split into segments and each segment will be a nested directory.
@@ -28,64 +40,304 @@ const tagNameSeparator = string(filepath.Separator)
 type TagsNode struct {
 	fs.Inode
 	repository *git.Repository
+	options    *Options
 }
 
 // NewTagsNode creates a new TagsNode.
-func NewTagsNode(repository *git.Repository) *TagsNode {
-	return &TagsNode{repository: repository}
+func NewTagsNode(repository *git.Repository, options *Options) *TagsNode {
+	return &TagsNode{repository: repository, options: options}
 }
 
-// Lookup returns a tag commit tree node or a tag segment node.
-// If tag name is "foo", it will return a tag commit tree node.
+// Lookup returns a tag tree node, a tag file node, a tag metadata file node or a tag segment node.
+// If tag name is "foo", it will return a node of the tagged object.
 // If tag name is "foo/bar", it will return a tag segment node with name "bar".
+// If the name is "foo.tag" and "foo" is an annotated tag, it will return the tag metadata file.
+// If the name is "foo.signature" and "foo" is an annotated tag, it will return the tag signature
+// verification result when the verifier is configured.
 // It returns ENOENT if the name is not found.
-func (node *TagsNode) Lookup(ctx context.Context, name string, _ *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
-	logger := slog.Default().With(slog.String("lookupTagName", name))
-	revision := revisionTagName(name)
-	tags, err := node.repository.Tags()
+func (node *TagsNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	return lookupTag(ctx, &node.Inode, out, node.repository, node.options, "", name)
+}
+
+// Readdir returns a list of tag names.
+// If tag name is "foo/bar", it will return "foo" directory with "bar" directory inside.
+func (node *TagsNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
+	return readTagsDir(&node.Inode, node.repository, node.options, "")
+}
+
+// lookupTag looks up the child of the tag directory with the tag prefix.
+// Tags are not expected to be moved, so the kernel caches the tagged objects for a long time
+// if the references are watched, see setReferenceEntryTimeout.
+func lookupTag(
+	ctx context.Context,
+	parent *fs.Inode,
+	out *fuse.EntryOut,
+	repository *git.Repository,
+	options *Options,
+	tagPrefix string,
+	name string,
+) (*fs.Inode, syscall.Errno) {
+	logger := slog.Default().
+		With(slog.String("lookupTagName", name)).
+		With(slog.String("tagPrefix", tagPrefix))
+	revision := revisionTagName(tagPrefix + name)
+	refNode, err := options.referenceIndex(repository).Lookup(revision)
 	if err != nil {
 		logger.Error("Error lookup tag", slog.String("error", err.Error()))
 		return nil, syscall.ENOENT
 	}
-	ok, hasPrefix := iter.HasReference(tags, revision)
-	if ok {
-		branchNode, err := NewObjectTreeNodeByRevision(node.repository, revision)
+	if refNode != nil && refNode.Reference() != nil {
+		tagNode, attr, err := newTagNode(parent, name, repository, options, revision)
 		if err != nil {
-			logger.Error("Error lookup tag object tree", slog.String("error", err.Error()))
+			logger.Error("Error lookup tag object", slog.String("error", err.Error()))
 			return nil, syscall.ENOENT
 		}
-		logger.Info("Tag object tree found")
-		return node.NewInode(ctx, branchNode, fs.StableAttr{Mode: syscall.S_IFDIR}), 0
+		logger.Info("Tag object found")
+		options.setReferenceEntryTimeout(out)
+		return newEntryInode(ctx, parent, out, tagNode, attr), 0
+	}
+	if tagName, isMetadata := strings.CutSuffix(name, tagMetadataFileSuffix); isMetadata {
+		metadataNode, err := newTagMetadataNode(repository, revisionTagName(tagPrefix+tagName))
+		if err == nil {
+			logger.Info("Tag metadata found")
+			options.setReferenceEntryTimeout(out)
+			return newEntryInode(ctx, parent, out, metadataNode, fs.StableAttr{Mode: syscall.S_IFREG}), 0
+		}
 	}
-	if !hasPrefix {
+	if tagName, isSignature := strings.CutSuffix(name, signatureFileSuffix); isSignature && options.Verifier != nil {
+		signatureNode, err := newTagSignatureNode(repository, options.Verifier, revisionTagName(tagPrefix+tagName))
+		if err == nil {
+			logger.Info("Tag signature found")
+			options.setReferenceEntryTimeout(out)
+			return newEntryInode(ctx, parent, out, signatureNode, fs.StableAttr{Mode: syscall.S_IFREG}), 0
+		}
+	}
+	if refNode == nil {
 		logger.Warn("Tag not found")
 		return nil, syscall.ENOENT
 	}
 	logger.Info("Tag segment found")
 
-	return node.NewInode(
+	return newUncountedDirEntryInode(
 		ctx,
-		NewTagSegmentNode(node.repository, name+tagNameSeparator),
-		fs.StableAttr{Mode: syscall.S_IFDIR},
+		parent,
+		out,
+		NewTagSegmentNode(repository, options, tagPrefix+name+tagNameSeparator),
+		options.pathStableAttr(parent, name),
 	), 0
 }
 
-// Readdir returns a list of tag names.
-// If tag name is "foo/bar", it will return "foo" directory with "bar" directory inside.
-func (node *TagsNode) Readdir(_ context.Context) (fs.DirStream, syscall.Errno) {
-	tagRefs, err := node.repository.Tags()
+// readTagsDir returns the entries of the tag directory with the tag prefix, sorted by name.
+// Annotated tags are accompanied with the tag metadata files,
+// and the signature verification files if the verifier is configured.
+func readTagsDir(parent *fs.Inode, repository *git.Repository, options *Options, tagPrefix string) (fs.DirStream, syscall.Errno) {
+	refNode, err := options.referenceIndex(repository).Lookup(strings.TrimSuffix(revisionTagName(tagPrefix), tagNameSeparator))
 	if err != nil {
 		return nil, syscall.ENOENT
 	}
-	slog.Default().Info("Dir of repository tags has been read")
-	return iter.NewDirStreamAdapter[*plumbing.Reference](
-		tagRefs,
-		func(tagRef *plumbing.Reference) fuse.DirEntry {
-			tagName := bareTagName(tagRef.Name().String())
-			segments := strings.Split(tagName, tagNameSeparator)
-			return fuse.DirEntry{Name: segments[0], Mode: syscall.S_IFDIR}
-		},
-	), 0
+	var entries []fuse.DirEntry
+	if refNode != nil {
+		for _, child := range refNode.Children() {
+			entries = append(entries, tagDirEntries(parent, repository, options, child)...)
+		}
+	}
+	slog.Default().Info("Dir of repository tags has been read", slog.String("tagPrefix", tagPrefix))
+	return fs.NewListDirStream(entries), 0
+}
+
+// tagDirEntries returns the directory entries of the child of the tag directory,
+// with the modes and the inode numbers the entries have when they are looked up.
+// The child is a segment directory if it is not a tag. The child is a tag if it is a reference,
+// even if there are tags nested in it, the same as the lookup prefers the tag.
+// The tag is peeled once, without reading the tagged tree, since the directory of many tags
+// is read by every listing.
+func tagDirEntries(parent *fs.Inode, repository *git.Repository, options *Options, child refindex.Child) []fuse.DirEntry {
+	tagRef := child.Node.Reference()
+	if tagRef == nil {
+		return []fuse.DirEntry{{Name: child.Name, Mode: syscall.S_IFDIR, Ino: options.inodeTable().peek(pathInoKey(parent, child.Name))}}
+	}
+	logger := slog.Default().With(slog.String("tag", tagRef.Name().String()))
+	peeled, err := peelTag(repository, tagRef.Name().String())
+	if err != nil {
+		logger.Warn("Error read tag object", slog.String("error", err.Error()))
+		return nil
+	}
+	entry := fuse.DirEntry{Name: child.Name}
+	switch peeled.object.Type() {
+	case plumbing.CommitObject:
+		entry.Mode = syscall.S_IFDIR
+		entry.Ino = options.inodeTable().peek(treeInoKey(peeled.commit.TreeHash, parent, child.Name))
+	case plumbing.TreeObject:
+		entry.Mode = syscall.S_IFDIR
+		entry.Ino = options.inodeTable().peek(treeInoKey(peeled.object.Hash(), parent, child.Name))
+	case plumbing.BlobObject:
+		fileNode, err := peeled.fileNode(tagRef.Name().String())
+		if err != nil {
+			logger.Warn("Error read tag object", slog.String("error", err.Error()))
+			return nil
+		}
+		entry.Mode = syscall.S_IFREG
+		entry.Ino = options.inodeTable().peek(options.fileInoKey(fileNode))
+	}
+	entries := []fuse.DirEntry{entry}
+	if peeled.tag != nil {
+		entries = append(entries, fuse.DirEntry{Name: child.Name + tagMetadataFileSuffix, Mode: syscall.S_IFREG})
+	}
+	if peeled.tag != nil && options.Verifier != nil {
+		entries = append(entries, fuse.DirEntry{Name: child.Name + signatureFileSuffix, Mode: syscall.S_IFREG})
+	}
+	return entries
+}
+
+// peeledTag is the object the tag points to, with the annotated tags peeled.
+type peeledTag struct {
+	// object is the tagged commit, tree or blob.
+	object plumbing.EncodedObject
+	// commit is the decoded object if it is a commit.
+	commit *object.Commit
+	// tag is the annotated tag the reference points to, it is nil for lightweight tags.
+	tag *object.Tag
+	// modTime is the tagger time of the annotated tag.
+	modTime time.Time
+}
+
+// peelTag peels the annotated tags the tag reference points to, until the tagged object.
+func peelTag(repository *git.Repository, revision string) (*peeledTag, error) {
+	ref, err := repository.Reference(plumbing.ReferenceName(revision), true)
+	if err != nil {
+		return nil, fmt.Errorf("repository: reference: %v", err)
+	}
+	peeled := &peeledTag{}
+	hash := ref.Hash()
+	for {
+		obj, err := repository.Storer.EncodedObject(plumbing.AnyObject, hash)
+		if err != nil {
+			return nil, fmt.Errorf("repository: object: %v", err)
+		}
+		switch obj.Type() {
+		case plumbing.TagObject:
+			tag, err := object.DecodeTag(repository.Storer, obj)
+			if err != nil {
+				return nil, fmt.Errorf("decode tag: %v", err)
+			}
+			if peeled.tag == nil {
+				peeled.tag = tag
+				peeled.modTime = tag.Tagger.When
+			}
+			hash = tag.Target
+		case plumbing.CommitObject:
+			commit, err := object.DecodeCommit(repository.Storer, obj)
+			if err != nil {
+				return nil, fmt.Errorf("decode commit: %v", err)
+			}
+			peeled.object = obj
+			peeled.commit = commit
+			return peeled, nil
+		case plumbing.TreeObject, plumbing.BlobObject:
+			peeled.object = obj
+			return peeled, nil
+		default:
+			return nil, fmt.Errorf("unsupported tagged object type: %s", obj.Type())
+		}
+	}
+}
+
+// fileNode creates the file node of the tagged blob.
+func (peeled *peeledTag) fileNode(revision string) (*FileNode, error) {
+	blob, err := object.DecodeBlob(peeled.object)
+	if err != nil {
+		return nil, fmt.Errorf("decode blob: %v", err)
+	}
+	file := object.NewFile(path.Base(bareTagName(revision)), filemode.Regular, blob)
+	return NewFileNode(file, revision, nil, peeled.modTime), nil
+}
+
+// newTagNode creates a node of the object the tag points to.
+// Annotated tags are peeled to the tagged object, the tagger time is used as the modification time
+// of the tagged tree or blob. Commits are represented by their trees, trees are represented as directories
+// and blobs are represented as files. It returns the node together with its stable attributes
+// for the lookup by the name in the parent.
+func newTagNode(
+	parent *fs.Inode,
+	name string,
+	repository *git.Repository,
+	options *Options,
+	revision string,
+) (fs.InodeEmbedder, fs.StableAttr, error) {
+	peeled, err := peelTag(repository, revision)
+	if err != nil {
+		return nil, fs.StableAttr{}, err
+	}
+	switch peeled.object.Type() {
+	case plumbing.CommitObject:
+		tree, err := peeled.commit.Tree()
+		if err != nil {
+			return nil, fs.StableAttr{}, fmt.Errorf("commit tree: %v", err)
+		}
+		return NewObjectTreeNode(repository, options, revision, peeled.commit, tree),
+			options.treeStableAttr(parent, name, tree), nil
+	case plumbing.TreeObject:
+		tree, err := object.DecodeTree(repository.Storer, peeled.object)
+		if err != nil {
+			return nil, fs.StableAttr{}, fmt.Errorf("decode tree: %v", err)
+		}
+		return NewObjectTreeNodeByTree(repository, options, revision, tree, peeled.modTime),
+			options.treeStableAttr(parent, name, tree), nil
+	default:
+		fileNode, err := peeled.fileNode(revision)
+		if err != nil {
+			return nil, fs.StableAttr{}, err
+		}
+		return fileNode, options.fileStableAttr(fileNode), nil
+	}
+}
+
+// newTagMetadataNode creates a file node with the annotated tag object content,
+// the same as `git cat-file tag <tag>` prints: the tagged object, the tagger,
+// the message and the PGP or SSH signature.
+// It returns error if the tag is not annotated.
+func newTagMetadataNode(repository *git.Repository, revision string) (*TextFileNode, error) {
+	obj, tag, err := annotatedTag(repository, revision)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := obj.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("tag reader: %v", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("tag read: %v", err)
+	}
+	return NewTextFileNode(data, tag.Tagger.When), nil
+}
+
+// newTagSignatureNode creates a file node with the annotated tag signature verification result.
+// It returns error if the tag is not annotated.
+func newTagSignatureNode(repository *git.Repository, verifier *verify.Verifier, revision string) (*TextFileNode, error) {
+	_, tag, err := annotatedTag(repository, revision)
+	if err != nil {
+		return nil, err
+	}
+	return NewTextFileNode([]byte(verifier.VerifyTag(tag).String()), tag.Tagger.When), nil
+}
+
+// annotatedTag returns the annotated tag object the tag reference points to.
+func annotatedTag(repository *git.Repository, revision string) (plumbing.EncodedObject, *object.Tag, error) {
+	ref, err := repository.Reference(plumbing.ReferenceName(revision), true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("repository: reference: %v", err)
+	}
+	obj, err := repository.Storer.EncodedObject(plumbing.TagObject, ref.Hash())
+	if err != nil {
+		return nil, nil, fmt.Errorf("repository: tag object: %v", err)
+	}
+	tag, err := object.DecodeTag(repository.Storer, obj)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode tag: %v", err)
+	}
+	return obj, tag, nil
 }
 
 const revisionTagPrefix = "refs/tags/"
@@ -97,3 +349,10 @@ func bareTagName(revision string) string {
 func revisionTagName(tag string) string {
 	return revisionTagPrefix + tag
 }
+
+// Getattr returns the directory attributes.
+// Tags are not counted, since telling tagged directories from tagged files reads every tagged object.
+func (node *TagsNode) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	setUncountedDirAttr(&out.Attr)
+	return 0
+}
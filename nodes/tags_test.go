@@ -0,0 +1,89 @@
+package nodes
+
+import (
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestAnnotatedTags(t *testing.T) {
+	mountPoint := testdata.InitializePrepared(t, NewRootNode, func(repoPath string) error {
+		blob, err := runGitInput(repoPath, "blob content\n", "hash-object", "-w", "--stdin")
+		if err != nil {
+			return err
+		}
+		treeBlob, err := runGitInput(repoPath, "tree content\n", "hash-object", "-w", "--stdin")
+		if err != nil {
+			return err
+		}
+		tree, err := runGitInput(repoPath, "100644 blob "+treeBlob+"\ttreefile\n", "mktree")
+		if err != nil {
+			return err
+		}
+		for _, args := range [][]string{
+			{"tag", "-a", "-m", "Release v2.0.0", "v2.0.0", commits[3]},
+			{"tag", "-a", "-m", "Tree", "trees/v1", tree},
+			{"tag", "-a", "-m", "Blob", "blob", blob},
+			{"tag", "lightblob", blob},
+		} {
+			if _, err := runGit(repoPath, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	expected := map[string]string{
+		"tags/v2.0.0/testdir/testfile4": "content of testfile4\n",
+		"tags/trees/v1/treefile":        "tree content\n",
+		"tags/blob":                     "blob content\n",
+		"tags/lightblob":                "blob content\n",
+		"tags/v2.0.0.tag": "object " + commits[3] + "\n" +
+			"type commit\n" +
+			"tag v2.0.0\n" +
+			"tagger gitfs <gitfs@example.com> 1681128000 +0000\n" +
+			"\n" +
+			"Release v2.0.0\n",
+	}
+	for path, content := range expected {
+		actual, err := os.ReadFile(filepath.Join(mountPoint, path))
+		require.NoError(t, err, path)
+		require.Equal(t, content, string(actual), path)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(mountPoint, "tags"))
+	require.NoError(t, err)
+	names := dirEntriesNames(entries)
+	require.Subset(t, names, []string{"v2.0.0", "v2.0.0.tag", "blob", "blob.tag", "lightblob", "trees"})
+	require.NotContains(t, names, "v1.0.0.tag")
+	require.NotContains(t, names, "lightblob.tag")
+	for _, entry := range entries {
+		switch entry.Name() {
+		case "blob", "lightblob", "v2.0.0.tag", "blob.tag":
+			require.True(t, entry.Type().IsRegular(), entry.Name())
+		default:
+			require.True(t, entry.IsDir(), entry.Name())
+		}
+	}
+
+	// The tagged commits, trees and blobs are listed with the inode numbers they are looked up with.
+	inos := readDirInos(t, filepath.Join(mountPoint, "tags"))
+	for i, name := range inos.names {
+		if name != "v2.0.0" && name != "blob" && name != "lightblob" && name != "trees" {
+			continue
+		}
+		var stat syscall.Stat_t
+		require.NoError(t, syscall.Stat(filepath.Join(mountPoint, "tags", name), &stat), name)
+		require.Equal(t, stat.Ino, inos.inos[i], name)
+	}
+
+	entries, err = os.ReadDir(filepath.Join(mountPoint, "tags", "trees"))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"v1", "v1.tag"}, dirEntriesNames(entries))
+
+	_, err = os.Stat(filepath.Join(mountPoint, "tags", "v1.0.0.tag"))
+	require.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,55 @@
+package nodes
+
+import (
+	"context"
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"syscall"
+	"time"
+)
+
+var (
+	_ fs.InodeEmbedder = (*TextFileNode)(nil)
+	_ fs.NodeOpener    = (*TextFileNode)(nil)
+	_ fs.NodeReader    = (*TextFileNode)(nil)
+	_ fs.NodeGetattrer = (*TextFileNode)(nil)
+)
+
+// TextFileNode is a read-only file node with content generated by gitfs,
+// for example, a reflog or a tag description.
+type TextFileNode struct {
+	fs.Inode
+	data    []byte
+	modTime time.Time
+}
+
+// NewTextFileNode creates a new TextFileNode.
+func NewTextFileNode(data []byte, modTime time.Time) *TextFileNode {
+	return &TextFileNode{data: data, modTime: modTime}
+}
+
+// Open opens the file.
+// The content is kept in memory, so no file handle is needed.
+func (node *TextFileNode) Open(_ context.Context, _ uint32) (fh fs.FileHandle, fuseFlags uint32, errno syscall.Errno) {
+	return nil, 0, 0
+}
+
+// Read reads the file content at the given offset.
+func (node *TextFileNode) Read(_ context.Context, _ fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	if off >= int64(len(node.data)) {
+		return fuse.ReadResultData(nil), 0
+	}
+	end := off + int64(len(dest))
+	if end > int64(len(node.data)) {
+		end = int64(len(node.data))
+	}
+	return fuse.ReadResultData(node.data[off:end]), 0
+}
+
+// Getattr gets the file attributes.
+func (node *TextFileNode) Getattr(_ context.Context, _ fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Size = uint64(len(node.data))
+	out.Mode = syscall.S_IFREG | 0444
+	out.SetTimes(&node.modTime, &node.modTime, &node.modTime)
+	return 0
+}
@@ -0,0 +1,72 @@
+package nodes
+
+import (
+	"fmt"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	"syscall"
+)
+
+// Extended attributes carrying git object information.
+const (
+	blobXattrName      = "user.git.blob"
+	treeXattrName      = "user.git.tree"
+	commitXattrName    = "user.git.commit"
+	modeXattrName      = "user.git.mode"
+	revisionXattrName  = "user.git.revision"
+	signatureXattrName = "user.git.signature"
+)
+
+// xattr is an extended attribute of a node.
+type xattr struct {
+	name  string
+	value string
+}
+
+// appendCommitXattrs appends the revision and the commit hash attributes if they are known.
+func appendCommitXattrs(attrs []xattr, revision string, commit *object.Commit) []xattr {
+	if commit != nil {
+		attrs = append(attrs, xattr{name: commitXattrName, value: commit.Hash.String()})
+	}
+	if revision != "" {
+		attrs = append(attrs, xattr{name: revisionXattrName, value: revision})
+	}
+	return attrs
+}
+
+// formatMode formats the mode as git does, for example, "100644" or "040000".
+func formatMode(mode filemode.FileMode) string {
+	return fmt.Sprintf("%06o", uint32(mode))
+}
+
+// getxattr copies the value of the attribute with the given name into dest.
+// It returns the value size and ERANGE if dest is too small,
+// so the caller can retry with a bigger buffer.
+func getxattr(attrs []xattr, name string, dest []byte) (uint32, syscall.Errno) {
+	for _, attr := range attrs {
+		if attr.name != name {
+			continue
+		}
+		if len(dest) < len(attr.value) {
+			return uint32(len(attr.value)), syscall.ERANGE
+		}
+		return uint32(copy(dest, attr.value)), 0
+	}
+	return 0, syscall.Errno(fuse.ENOATTR)
+}
+
+// listxattr copies the null-terminated names of the attributes into dest.
+// It returns the names size and ERANGE if dest is too small,
+// so the caller can retry with a bigger buffer.
+func listxattr(attrs []xattr, dest []byte) (uint32, syscall.Errno) {
+	var names []byte
+	for _, attr := range attrs {
+		names = append(names, attr.name...)
+		names = append(names, 0)
+	}
+	if len(dest) < len(names) {
+		return uint32(len(names)), syscall.ERANGE
+	}
+	return uint32(copy(dest, names)), 0
+}
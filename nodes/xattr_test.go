@@ -0,0 +1,78 @@
+package nodes
+
+import (
+	"bytes"
+	"github.com/dsxack/gitfs/internal/testdata"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	"path/filepath"
+	"testing"
+)
+
+func TestXattrs(t *testing.T) {
+	blob := plumbing.ComputeHash(plumbing.BlobObject, []byte("content of testfile4\n"))
+	tests := []struct {
+		path     string
+		expected map[string]string
+	}{
+		{
+			path: "branches/nested/dir/test/testdir/testfile4",
+			expected: map[string]string{
+				"user.git.blob":     blob.String(),
+				"user.git.mode":     "100644",
+				"user.git.commit":   commits[3],
+				"user.git.revision": "refs/heads/nested/dir/test",
+			},
+		},
+		{
+			path: "commits/" + commits[0],
+			expected: map[string]string{
+				"user.git.tree":     "80c6fa57980e84791edf5858dde177856e4fdd74",
+				"user.git.mode":     "040000",
+				"user.git.commit":   commits[0],
+				"user.git.revision": commits[0],
+			},
+		},
+		{
+			path: "index/testdir/testfile4",
+			expected: map[string]string{
+				"user.git.blob": blob.String(),
+				"user.git.mode": "100644",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			// Files with the same content share the inode and its attributes,
+			// so every view is checked in a fresh mount.
+			mountPoint := testdata.Initialize(t, NewRootNode)
+			path := filepath.Join(mountPoint, test.path)
+			size, err := unix.Listxattr(path, nil)
+			require.NoError(t, err)
+			names := make([]byte, size)
+			_, err = unix.Listxattr(path, names)
+			require.NoError(t, err)
+			var actualNames []string
+			for _, name := range bytes.Split(bytes.TrimSuffix(names, []byte{0}), []byte{0}) {
+				actualNames = append(actualNames, string(name))
+			}
+			var expectedNames []string
+			for name, value := range test.expected {
+				expectedNames = append(expectedNames, name)
+				dest := make([]byte, 64)
+				n, err := unix.Getxattr(path, name, dest)
+				require.NoError(t, err, name)
+				require.Equal(t, value, string(dest[:n]), name)
+			}
+			require.ElementsMatch(t, expectedNames, actualNames)
+
+			_, err = unix.Getxattr(path, "user.git.blob", make([]byte, 1))
+			if _, ok := test.expected["user.git.blob"]; ok {
+				require.ErrorIs(t, err, unix.ERANGE)
+			} else {
+				require.ErrorIs(t, err, unix.ENODATA)
+			}
+		})
+	}
+}